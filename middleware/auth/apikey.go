@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/pkg/config"
+)
+
+const (
+	apiKeyHashPrefix          = "sha256:"
+	defaultAPIKeyQuotaWindow  = time.Minute
+	apiKeyQuotaSweepThreshold = 1024
+)
+
+// APIKey api key 信息, 只保存 key 的 hash, 见 HashAPIKey
+type APIKey struct {
+	ID    string `mapstructure:"id"`
+	Owner string `mapstructure:"owner"`
+	Hash  string `mapstructure:"hash"`
+	// Scopes 授予的权限, 认证通过后作为 Principal.Permissions
+	Scopes []string `mapstructure:"scopes"`
+	// ExpiresAt 过期时间, 为空时不过期。配置中使用 RFC3339 格式
+	ExpiresAt time.Time `mapstructure:"expires_at"`
+	Revoked   bool      `mapstructure:"revoked"`
+	// Quota 每个 QuotaWindow 内允许的请求数, 0 不限制
+	Quota int64 `mapstructure:"quota"`
+	// QuotaWindow 默认 1m
+	QuotaWindow time.Duration `mapstructure:"quota_window"`
+}
+
+// APIKeyStore api key 存储
+type APIKeyStore interface {
+	// Lookup 根据 hash 获取 key, 不存在时返回 nil, nil
+	Lookup(ctx coreContext.Context, hash string) (*APIKey, error)
+}
+
+// APIKeyConfig api key 配置
+//
+//	api_key:
+//	  header: X-API-Key
+//	  keys:
+//	    - id: billing
+//	      owner: billing-service
+//	      hash: sha256:...
+//	      scopes: ["order:read"]
+//	      expires_at: 2030-01-01T00:00:00Z
+//	      quota: 600
+//	      quota_window: 1m
+type APIKeyConfig struct {
+	// Header 默认 X-API-Key
+	Header string   `mapstructure:"header"`
+	Keys   []APIKey `mapstructure:"keys"`
+}
+
+// HashAPIKey 计算 api key 的 hash, 存储和配置中只保存 hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateAPIKey 生成随机 api key, 返回 key 和 hash。key 只在生成时返回一次, 交给调用方保存
+func GenerateAPIKey(prefix string) (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("generate api key: %w", err)
+	}
+	key = prefix + hex.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// MemoryAPIKeyStore 内存中的 api key 存储, 支持运行时添加和吊销
+type MemoryAPIKeyStore struct {
+	mu     sync.RWMutex
+	byHash map[string]*APIKey
+}
+
+func NewMemoryAPIKeyStore(keys ...APIKey) (*MemoryAPIKeyStore, error) {
+	s := &MemoryAPIKeyStore{byHash: make(map[string]*APIKey, len(keys))}
+	for _, k := range keys {
+		if err := s.Add(k); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加 key, hash 格式错误或者 ID, hash 重复时返回错误
+func (s *MemoryAPIKeyStore) Add(key APIKey) error {
+	if key.ID == "" {
+		return fmt.Errorf("api key id is required")
+	}
+	if !strings.HasPrefix(key.Hash, apiKeyHashPrefix) || len(key.Hash) != len(apiKeyHashPrefix)+sha256.Size*2 {
+		return fmt.Errorf("api key %s: hash must be %s<hex>, see auth.HashAPIKey", key.ID, apiKeyHashPrefix)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.byHash[key.Hash]; exists {
+		return fmt.Errorf("api key %s: duplicate hash", key.ID)
+	}
+	for _, k := range s.byHash {
+		if k.ID == key.ID {
+			return fmt.Errorf("api key %s: duplicate id", key.ID)
+		}
+	}
+	s.byHash[key.Hash] = &key
+	return nil
+}
+
+// Revoke 吊销 key, key 不存在时返回 false
+func (s *MemoryAPIKeyStore) Revoke(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for hash, k := range s.byHash {
+		if k.ID == id {
+			revoked := *k
+			revoked.Revoked = true
+			s.byHash[hash] = &revoked
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryAPIKeyStore) Lookup(ctx coreContext.Context, hash string) (*APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.byHash[hash], nil
+}
+
+// APIKeyManager api key 认证方式, 名称为 api_key。
+// 校验 key 是否存在, 过期, 吊销以及请求配额, 认证通过后 Principal.ID 为 key id, Name 为 owner, Permissions 为 scopes
+type APIKeyManager struct {
+	header string
+	store  APIKeyStore
+	now    func() time.Time
+
+	mu     sync.Mutex
+	quotas map[string]*apiKeyWindow
+}
+
+type apiKeyWindow struct {
+	start  time.Time
+	window time.Duration
+	count  int64
+}
+
+// NewAPIKeyManager header 为空时使用 X-API-Key
+func NewAPIKeyManager(header string, store APIKeyStore) *APIKeyManager {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	return &APIKeyManager{
+		header: header,
+		store:  store,
+		now:    time.Now,
+		quotas: make(map[string]*apiKeyWindow),
+	}
+}
+
+// LoadAPIKeyManager 从配置中加载 api key, key 为配置路径, 见 APIKeyConfig
+func LoadAPIKeyManager(key string) (*APIKeyManager, error) {
+	conf := APIKeyConfig{}
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	))
+	if err := config.Unmarshal(key, &conf, hook); err != nil {
+		return nil, fmt.Errorf("load api key config %s: %w", key, err)
+	}
+	store, err := NewMemoryAPIKeyStore(conf.Keys...)
+	if err != nil {
+		return nil, fmt.Errorf("load api key config %s: %w", key, err)
+	}
+	return NewAPIKeyManager(conf.Header, store), nil
+}
+
+func (m *APIKeyManager) Name() string {
+	return NameAPIKey
+}
+
+func (m *APIKeyManager) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	raw := strings.TrimSpace(ctx.Header().Get(m.header))
+	if raw == "" {
+		return nil, nil
+	}
+	key, err := m.store.Lookup(ctx, HashAPIKey(raw))
+	if err != nil {
+		return nil, errors.New(err, code.InvalidCredentialsErrCode, "lookup api key failed")
+	}
+	if key == nil {
+		return nil, errors.New(nil, code.InvalidCredentialsErrCode, "unknown api key")
+	}
+	now := m.now()
+	if key.Revoked {
+		return nil, errors.New(nil, code.InvalidCredentialsErrCode, "api key "+key.ID+" is revoked")
+	}
+	if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+		return nil, errors.New(nil, code.InvalidCredentialsErrCode, "api key "+key.ID+" is expired")
+	}
+	if retryAfter, ok := m.allow(key, now); !ok {
+		if resp, ok := ctx.(interface{ Response() http.ResponseWriter }); ok && resp.Response() != nil {
+			resp.Response().Header().Set("Retry-After", strconv.FormatInt(int64((retryAfter+time.Second-1)/time.Second), 10))
+		}
+		ctx.Log().Infof("auth: api key quota exceeded. key_id: %s, owner: %s, trace_id: %s", key.ID, key.Owner, ctx.GetRequestID())
+		return nil, errors.New(nil, code.APIKeyQuotaExceededErrCode, key.ID)
+	}
+
+	ctx.Log().Infof("auth: api key used. key_id: %s, owner: %s, trace_id: %s", key.ID, key.Owner, ctx.GetRequestID())
+	return &coreContext.Principal{
+		ID:            key.ID,
+		Name:          key.Owner,
+		Authenticator: NameAPIKey,
+		Permissions:   key.Scopes,
+		Claims: map[string]interface{}{
+			"owner":  key.Owner,
+			"scopes": key.Scopes,
+		},
+	}, nil
+}
+
+// allow 按固定窗口统计 key 的请求数, 超过配额时返回到下一个窗口的时间
+func (m *APIKeyManager) allow(key *APIKey, now time.Time) (time.Duration, bool) {
+	if key.Quota <= 0 {
+		return 0, true
+	}
+	window := key.QuotaWindow
+	if window <= 0 {
+		window = defaultAPIKeyQuotaWindow
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.quotas) > apiKeyQuotaSweepThreshold {
+		// 每个 key 的窗口长度可能不同, 使用各自的窗口判断是否过期
+		for id, w := range m.quotas {
+			if now.Sub(w.start) >= w.window {
+				delete(m.quotas, id)
+			}
+		}
+	}
+	w, ok := m.quotas[key.ID]
+	if !ok || w.window != window || now.Sub(w.start) >= window {
+		w = &apiKeyWindow{start: now, window: window}
+		m.quotas[key.ID] = w
+	}
+	if w.count >= key.Quota {
+		return w.start.Add(window).Sub(now), false
+	}
+	w.count++
+	return 0, true
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// Authenticator 认证方式
+type Authenticator interface {
+	// Name 认证方式名称, 路由通过名称指定认证方式
+	Name() string
+	// Authenticate 请求中没有当前认证方式的凭证时返回 nil, nil, 继续尝试下一个认证方式。
+	// 凭证无效时返回错误, 不再尝试其他认证方式
+	Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error)
+}
+
+// Challenger 认证失败时通过 WWW-Authenticate 告知客户端认证方式
+type Challenger interface {
+	Challenge() string
+}
+
+var (
+	mu             sync.RWMutex
+	authenticators = make(map[string]Authenticator)
+	// defaultNames 路由没有指定认证方式时按顺序尝试
+	defaultNames []string
+)
+
+// Register 注册认证方式, 按注册顺序加入默认认证方式。名称重复时 panic
+func Register(authenticator Authenticator) {
+	mu.Lock()
+	defer mu.Unlock()
+	name := authenticator.Name()
+	if _, exists := authenticators[name]; exists {
+		panic(fmt.Sprintf("auth: authenticator %s already registered", name))
+	}
+	authenticators[name] = authenticator
+	defaultNames = append(defaultNames, name)
+}
+
+// SetDefault 设置路由没有指定认证方式时使用的认证方式和顺序, 名称没有注册时 panic
+func SetDefault(names ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, name := range names {
+		if _, exists := authenticators[name]; !exists {
+			panic(fmt.Sprintf("auth: authenticator %s not registered", name))
+		}
+	}
+	defaultNames = append([]string(nil), names...)
+}
+
+// Get 获取已注册的认证方式
+func Get(name string) (Authenticator, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	a, ok := authenticators[name]
+	return a, ok
+}
+
+// Enabled 是否注册了认证方式
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return len(authenticators) > 0
+}
+
+// Reset 清空已注册的认证方式
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	authenticators = make(map[string]Authenticator)
+	defaultNames = nil
+}
+
+// Authenticate 按顺序尝试认证方式, 认证通过后将 Principal 保存到 ctx 中。names 为空时使用默认认证方式
+func Authenticate(ctx coreContext.Context, names ...string) errors.Error {
+	mu.RLock()
+	if len(names) == 0 {
+		names = defaultNames
+	}
+	selected := make([]Authenticator, 0, len(names))
+	for _, name := range names {
+		a, ok := authenticators[name]
+		if !ok {
+			mu.RUnlock()
+			return errors.New(nil, code.UnknownAuthenticatorErrCode, name)
+		}
+		selected = append(selected, a)
+	}
+	mu.RUnlock()
+
+	for _, a := range selected {
+		principal, err := a.Authenticate(ctx)
+		if err != nil {
+			ctx.Log().Infof("auth: authenticator %s rejected request. err: %s", a.Name(), err.Error())
+			challenge(ctx, selected)
+			return err
+		}
+		if principal != nil {
+			if principal.Authenticator == "" {
+				principal.Authenticator = a.Name()
+			}
+			ctx.SetPrincipal(principal)
+			return nil
+		}
+	}
+
+	challenge(ctx, selected)
+	return errors.New(nil, code.UnauthenticatedErrCode)
+}
+
+// challenge 设置 WWW-Authenticate, 只有 ctx 可以获取 response 时生效
+func challenge(ctx coreContext.Context, selected []Authenticator) {
+	resp, ok := ctx.(interface{ Response() http.ResponseWriter })
+	if !ok || resp.Response() == nil {
+		return
+	}
+	for _, a := range selected {
+		if c, ok := a.(Challenger); ok && c.Challenge() != "" {
+			resp.Response().Header().Add("WWW-Authenticate", c.Challenge())
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/context/contexttest"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/pkg/config"
+	"github.com/rentiansheng/go-api-component/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tokenValidator(valid string) TokenValidator {
+	return func(ctx coreContext.Context, token string) (*coreContext.Principal, error) {
+		if token != valid {
+			return nil, nil
+		}
+		return &coreContext.Principal{ID: "u-" + token}, nil
+	}
+}
+
+func registerTestAuthenticators(t *testing.T) {
+	t.Helper()
+	Reset()
+	t.Cleanup(Reset)
+	Register(NewBearer(tokenValidator("t1")))
+	Register(NewAPIKey("", tokenValidator("k1")))
+	Register(NewBasic("api", func(ctx coreContext.Context, username, password string) (*coreContext.Principal, error) {
+		if username == "admin" && password == "123456" {
+			return &coreContext.Principal{ID: username, Roles: []string{"admin"}}, nil
+		}
+		return nil, fmt.Errorf("wrong password")
+	}))
+	Register(NewCookieSession("", tokenValidator("s1")))
+}
+
+func TestAuthenticate(t *testing.T) {
+	registerTestAuthenticators(t)
+
+	tests := []struct {
+		name          string
+		setup         func(req *http.Request)
+		names         []string
+		principalID   string
+		authenticator string
+		errCode       int32
+	}{
+		{"bearer", func(req *http.Request) { req.Header.Set("Authorization", "bearer t1") }, nil, "u-t1", NameBearer, 0},
+		{"api key", func(req *http.Request) { req.Header.Set("X-API-Key", "k1") }, nil, "u-k1", NameAPIKey, 0},
+		{"basic", func(req *http.Request) { req.SetBasicAuth("admin", "123456") }, nil, "admin", NameBasic, 0},
+		{"session", func(req *http.Request) { req.AddCookie(&http.Cookie{Name: "session_id", Value: "s1"}) }, nil, "u-s1", NameSession, 0},
+		{"no credentials", func(req *http.Request) {}, nil, "", "", code.UnauthenticatedErrCode},
+		{"invalid token", func(req *http.Request) { req.Header.Set("Authorization", "Bearer t2") }, nil, "", "", code.InvalidCredentialsErrCode},
+		{"wrong password", func(req *http.Request) { req.SetBasicAuth("admin", "x") }, nil, "", "", code.InvalidCredentialsErrCode},
+		{"malformed basic", func(req *http.Request) { req.Header.Set("Authorization", "Basic !!!") }, nil, "", "", code.InvalidCredentialsErrCode},
+		// 无效的凭证不会继续尝试其他认证方式
+		{"invalid first", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer t2")
+			req.Header.Set("X-API-Key", "k1")
+		}, nil, "", "", code.InvalidCredentialsErrCode},
+		// 路由指定的认证方式
+		{"selected", func(req *http.Request) { req.Header.Set("Authorization", "Bearer t1") }, []string{NameAPIKey}, "", "", code.UnauthenticatedErrCode},
+		{"selected order", func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer t2")
+			req.Header.Set("X-API-Key", "k1")
+		}, []string{NameAPIKey, NameBearer}, "u-k1", NameAPIKey, 0},
+		{"unknown", func(req *http.Request) {}, []string{"oauth"}, "", "", code.UnknownAuthenticatorErrCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+			ctx, _ := contexttest.NewContext(req)
+
+			err := Authenticate(ctx, tt.names...)
+			if tt.errCode != 0 {
+				require.Error(t, err)
+				assert.Equal(t, tt.errCode, err.Code())
+				// 校验函数的错误不返回给调用方
+				assert.NotContains(t, err.Message(), "wrong password")
+				assert.Nil(t, ctx.Principal())
+				return
+			}
+			require.NoError(t, err)
+			require.NotNil(t, ctx.Principal())
+			assert.Equal(t, tt.principalID, ctx.Principal().ID)
+			assert.Equal(t, tt.authenticator, ctx.Principal().Authenticator)
+		})
+	}
+}
+
+func TestAuthenticate_Challenge(t *testing.T) {
+	registerTestAuthenticators(t)
+
+	ctx, w := contexttest.NewContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Error(t, Authenticate(ctx))
+	assert.Equal(t, []string{"Bearer", `Basic realm="api"`}, w.Header().Values("WWW-Authenticate"))
+}
+
+func TestRegister(t *testing.T) {
+	Reset()
+	defer Reset()
+	assert.False(t, Enabled())
+
+	Register(NewBearer(tokenValidator("t1")))
+	Register(Named("partner", NewBearer(tokenValidator("p1"))))
+	assert.True(t, Enabled())
+	assert.Panics(t, func() { Register(NewBearer(tokenValidator("t1"))) })
+	assert.Panics(t, func() { SetDefault("oauth") })
+
+	a, ok := Get("partner")
+	require.True(t, ok)
+	assert.Equal(t, "Bearer", a.(Challenger).Challenge())
+
+	// 默认只使用 partner
+	SetDefault("partner")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer p1")
+	ctx, _ := contexttest.NewContext(req)
+	require.NoError(t, Authenticate(ctx))
+	assert.Equal(t, "partner", ctx.Principal().Authenticator)
+}
+
+func TestJWT(t *testing.T) {
+	Reset()
+	defer Reset()
+	verifier, err := jwt.NewVerifier(jwt.Config{Secret: "secret", Issuer: "auth"})
+	require.NoError(t, err)
+	Register(NewJWT(verifier))
+	signer, err := jwt.NewSigner(jwt.SignerConfig{Secret: "secret", Issuer: "auth", TTL: time.Hour})
+	require.NoError(t, err)
+
+	token, err := signer.Sign(jwt.Claims{"sub": "u1", "name": "Tom", "roles": []string{"admin", "dev"}, "tenant": "t1"})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	ctx, _ := contexttest.NewContext(req)
+	require.NoError(t, Authenticate(ctx))
+
+	p := ctx.Principal()
+	assert.Equal(t, "u1", p.ID)
+	assert.Equal(t, "Tom", p.Name)
+	assert.Equal(t, NameJWT, p.Authenticator)
+	assert.Equal(t, []string{"admin", "dev"}, p.Roles)
+	claims, ok := JWTClaims(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "t1", claims["tenant"])
+
+	expired, err := signer.Sign(jwt.Claims{"sub": "u1", "exp": time.Now().Add(-time.Hour).Unix()})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+expired)
+	ctx, _ = contexttest.NewContext(req)
+	eerr := Authenticate(ctx)
+	require.Error(t, eerr)
+	assert.Equal(t, code.TokenExpiredErrCode, eerr.Code())
+	_, ok = JWTClaims(ctx)
+	assert.False(t, ok)
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer a.b.c")
+	ctx, _ = contexttest.NewContext(req)
+	eerr = Authenticate(ctx)
+	require.Error(t, eerr)
+	assert.Equal(t, code.InvalidCredentialsErrCode, eerr.Code())
+	assert.NotContains(t, eerr.Message(), "malformed")
+
+	// 签名错误等校验细节不返回给调用方
+	other, err := jwt.NewSignerWithKey(jwt.SignerConfig{KeyID: "probe"}, []byte("other"))
+	require.NoError(t, err)
+	probe, err := other.Sign(jwt.Claims{"sub": "u1"})
+	require.NoError(t, err)
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+probe)
+	ctx, _ = contexttest.NewContext(req)
+	eerr = Authenticate(ctx)
+	require.Error(t, eerr)
+	assert.Equal(t, code.InvalidCredentialsErrCode, eerr.Code())
+	assert.Equal(t, "invalid credentials. err: invalid token", eerr.Message())
+	assert.ErrorIs(t, eerr, jwt.ErrSignature)
+}
+
+func TestAuthorize(t *testing.T) {
+	t.Cleanup(func() {
+		SetAuthorizer(NewRBAC(nil))
+		SetAuditor(logAudit)
+	})
+	config.GetViper().Set("rbac_test.roles", map[string][]string{
+		"Admin":  {"*"},
+		"editor": {"order:*", "user:read"},
+	})
+	rbac, err := LoadRBAC("rbac_test")
+	require.NoError(t, err)
+	SetAuthorizer(rbac)
+	var events []AuditEvent
+	SetAuditor(func(ctx coreContext.Context, event AuditEvent) { events = append(events, event) })
+
+	tests := []struct {
+		name      string
+		principal *coreContext.Principal
+		req       Requirement
+		errCode   int32
+	}{
+		{"no requirement", nil, Requirement{}, 0},
+		{"unauthenticated", nil, Requirement{Permissions: []string{"order:read"}}, code.UnauthenticatedErrCode},
+		{"wildcard", &coreContext.Principal{ID: "u1", Roles: []string{"admin"}}, Requirement{Permissions: []string{"user:write"}}, 0},
+		{"prefix", &coreContext.Principal{ID: "u1", Roles: []string{"editor"}}, Requirement{Permissions: []string{"order:write", "user:read"}}, 0},
+		{"missing one", &coreContext.Principal{ID: "u1", Roles: []string{"editor"}}, Requirement{Permissions: []string{"order:write", "user:write"}}, code.PermissionDeniedErrCode},
+		{"prefix boundary", &coreContext.Principal{ID: "u1", Roles: []string{"editor"}}, Requirement{Permissions: []string{"orders:read"}}, code.PermissionDeniedErrCode},
+		{"direct permission", &coreContext.Principal{ID: "u1", Permissions: []string{"report:read"}}, Requirement{Permissions: []string{"report:read"}}, 0},
+		{"any role", &coreContext.Principal{ID: "u1", Roles: []string{"Editor"}}, Requirement{Roles: []string{"admin", "editor"}}, 0},
+		{"role denied", &coreContext.Principal{ID: "u1", Roles: []string{"viewer"}}, Requirement{Roles: []string{"admin"}}, code.PermissionDeniedErrCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events = nil
+			ctx, _ := contexttest.NewContext(httptest.NewRequest(http.MethodGet, "/", nil))
+			if tt.principal != nil {
+				ctx.SetPrincipal(tt.principal)
+			}
+			tt.req.Method, tt.req.Route = http.MethodPost, "/orders"
+			err := Authorize(ctx, tt.req)
+			if tt.errCode == 0 {
+				assert.Nil(t, err)
+				assert.Empty(t, events)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, tt.errCode, err.Code())
+			require.Len(t, events, 1)
+			assert.Equal(t, ctx.GetRequestID(), events[0].TraceID)
+			assert.Equal(t, "/orders", events[0].Route)
+			assert.Equal(t, err.Message(), events[0].Reason)
+			if tt.principal != nil {
+				assert.Equal(t, tt.principal.ID, events[0].PrincipalID)
+			}
+		})
+	}
+
+	// 自定义策略
+	SetAuthorizer(AuthorizerFunc(func(ctx coreContext.Context, principal *coreContext.Principal, r Requirement) errors.Error {
+		if principal.ID == "owner" {
+			return nil
+		}
+		return errors.New(nil, code.PermissionDeniedErrCode, "owner")
+	}))
+	ctx, _ := contexttest.NewContext(httptest.NewRequest(http.MethodGet, "/", nil))
+	ctx.SetPrincipal(&coreContext.Principal{ID: "owner"})
+	assert.Nil(t, Authorize(ctx, Requirement{Roles: []string{"admin"}}))
+}
+
+func TestAPIKeyManager(t *testing.T) {
+	Reset()
+	t.Cleanup(Reset)
+	config.GetViper().Set("api_key_test", map[string]interface{}{
+		"header": "X-Token",
+		"keys": []map[string]interface{}{
+			{"id": "billing", "owner": "billing-service", "hash": HashAPIKey("k-billing"), "scopes": []string{"order:read"}, "quota": 2, "quota_window": "1m"},
+			{"id": "old", "owner": "legacy", "hash": HashAPIKey("k-old"), "expires_at": "2024-01-01T00:00:00Z"},
+		},
+	})
+	manager, err := LoadAPIKeyManager("api_key_test")
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	manager.now = func() time.Time { return now }
+	Register(manager)
+
+	key, hash, err := GenerateAPIKey("ak_")
+	require.NoError(t, err)
+	assert.Equal(t, HashAPIKey(key), hash)
+	store := manager.store.(*MemoryAPIKeyStore)
+	require.NoError(t, store.Add(APIKey{ID: "new", Owner: "ops", Hash: hash}))
+	assert.Error(t, store.Add(APIKey{ID: "new", Hash: HashAPIKey("other")}))
+	assert.Error(t, store.Add(APIKey{ID: "plain", Hash: "k-plain"}))
+
+	do := func(key string) (coreContext.Contexts, *httptest.ResponseRecorder, errors.Error) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Token", key)
+		ctx, w := contexttest.NewContext(req)
+		return ctx, w, Authenticate(ctx)
+	}
+
+	ctx, _, aerr := do("k-billing")
+	require.Nil(t, aerr)
+	p := ctx.Principal()
+	assert.Equal(t, "billing", p.ID)
+	assert.Equal(t, "billing-service", p.Name)
+	assert.Equal(t, NameAPIKey, p.Authenticator)
+	assert.Equal(t, []string{"order:read"}, p.Permissions)
+
+	// 配额
+	_, _, aerr = do("k-billing")
+	require.Nil(t, aerr)
+	_, w, aerr := do("k-billing")
+	require.NotNil(t, aerr)
+	assert.Equal(t, code.APIKeyQuotaExceededErrCode, aerr.Code())
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	now = now.Add(time.Minute)
+	_, _, aerr = do("k-billing")
+	assert.Nil(t, aerr)
+
+	for _, k := range []string{"k-old", "k-unknown"} {
+		_, _, aerr = do(k)
+		require.NotNil(t, aerr)
+		assert.Equal(t, code.InvalidCredentialsErrCode, aerr.Code(), k)
+	}
+
+	_, _, aerr = do(key)
+	assert.Nil(t, aerr)
+	assert.True(t, store.Revoke("new"))
+	_, _, aerr = do(key)
+	assert.NotNil(t, aerr)
+	assert.False(t, store.Revoke("missing"))
+}
+
+func TestAPIKeyManager_QuotaSweep(t *testing.T) {
+	store, err := NewMemoryAPIKeyStore()
+	require.NoError(t, err)
+	manager := NewAPIKeyManager("", store)
+	now := time.Now()
+	hourly := &APIKey{ID: "hourly", Quota: 1, QuotaWindow: time.Hour}
+	_, ok := manager.allow(hourly, now)
+	require.True(t, ok)
+	for i := 0; i < apiKeyQuotaSweepThreshold; i++ {
+		_, ok = manager.allow(&APIKey{ID: fmt.Sprintf("k%d", i), Quota: 1, QuotaWindow: time.Second}, now)
+		require.True(t, ok)
+	}
+
+	// 清理时使用每个 key 自己的窗口, 不能清理还在窗口内的 hourly
+	now = now.Add(2 * time.Second)
+	_, ok = manager.allow(&APIKey{ID: "short", Quota: 1, QuotaWindow: time.Second}, now)
+	require.True(t, ok)
+	assert.Len(t, manager.quotas, 2)
+	_, ok = manager.allow(hourly, now)
+	assert.False(t, ok)
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const (
+	NameBearer  = "bearer"
+	NameAPIKey  = "api_key"
+	NameBasic   = "basic"
+	NameSession = "session"
+
+	DefaultAPIKeyHeader  = "X-API-Key"
+	DefaultSessionCookie = "session_id"
+)
+
+// TokenValidator 校验 token, api key, session id 等凭证。返回 nil Principal 时视为凭证无效
+type TokenValidator func(ctx coreContext.Context, token string) (*coreContext.Principal, error)
+
+// BasicValidator 校验 basic auth 的用户名和密码。返回 nil Principal 时视为凭证无效
+type BasicValidator func(ctx coreContext.Context, username, password string) (*coreContext.Principal, error)
+
+// Named 修改认证方式的名称, 用于注册多个同类型的认证方式
+func Named(name string, a Authenticator) Authenticator {
+	return &named{Authenticator: a, name: name}
+}
+
+type named struct {
+	Authenticator
+	name string
+}
+
+func (n *named) Name() string {
+	return n.name
+}
+
+func (n *named) Challenge() string {
+	if c, ok := n.Authenticator.(Challenger); ok {
+		return c.Challenge()
+	}
+	return ""
+}
+
+// NewBearer Authorization: Bearer <token>
+func NewBearer(validate TokenValidator) Authenticator {
+	return &bearer{validate: validate}
+}
+
+type bearer struct {
+	validate TokenValidator
+}
+
+func (b *bearer) Name() string {
+	return NameBearer
+}
+
+func (b *bearer) Challenge() string {
+	return "Bearer"
+}
+
+func (b *bearer) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	token, ok := authorization(ctx, "Bearer")
+	if !ok {
+		return nil, nil
+	}
+	return validated(b.validate(ctx, token))
+}
+
+// NewAPIKey 从请求头中获取 api key, header 为空时使用 X-API-Key
+func NewAPIKey(header string, validate TokenValidator) Authenticator {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	return &apiKey{header: header, validate: validate}
+}
+
+type apiKey struct {
+	header   string
+	validate TokenValidator
+}
+
+func (a *apiKey) Name() string {
+	return NameAPIKey
+}
+
+func (a *apiKey) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	key := strings.TrimSpace(ctx.Header().Get(a.header))
+	if key == "" {
+		return nil, nil
+	}
+	return validated(a.validate(ctx, key))
+}
+
+// NewBasic Authorization: Basic base64(username:password)
+func NewBasic(realm string, validate BasicValidator) Authenticator {
+	return &basic{realm: realm, validate: validate}
+}
+
+type basic struct {
+	realm    string
+	validate BasicValidator
+}
+
+func (b *basic) Name() string {
+	return NameBasic
+}
+
+func (b *basic) Challenge() string {
+	if b.realm == "" {
+		return "Basic"
+	}
+	return `Basic realm="` + strings.ReplaceAll(b.realm, `"`, `\"`) + `"`
+}
+
+func (b *basic) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	if _, ok := authorization(ctx, "Basic"); !ok {
+		return nil, nil
+	}
+	req := &http.Request{Header: ctx.Header()}
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, errors.New(nil, code.InvalidCredentialsErrCode, "malformed basic credentials")
+	}
+	return validated(b.validate(ctx, username, password))
+}
+
+// NewCookieSession 从 cookie 中获取 session id, cookie 为空时使用 session_id
+func NewCookieSession(cookie string, validate TokenValidator) Authenticator {
+	if cookie == "" {
+		cookie = DefaultSessionCookie
+	}
+	return &cookieSession{cookie: cookie, validate: validate}
+}
+
+type cookieSession struct {
+	cookie   string
+	validate TokenValidator
+}
+
+func (s *cookieSession) Name() string {
+	return NameSession
+}
+
+func (s *cookieSession) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	req := &http.Request{Header: ctx.Header()}
+	c, err := req.Cookie(s.cookie)
+	if err != nil || c.Value == "" {
+		return nil, nil
+	}
+	return validated(s.validate(ctx, c.Value))
+}
+
+// authorization 获取 Authorization 中指定 scheme 的凭证, scheme 不区分大小写
+func authorization(ctx coreContext.Context, scheme string) (string, bool) {
+	value := ctx.Header().Get("Authorization")
+	if len(value) <= len(scheme) || !strings.EqualFold(value[:len(scheme)], scheme) || value[len(scheme)] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(value[len(scheme)+1:]), true
+}
+
+// validated 将校验结果转换为认证结果, 校验返回 errors.Error 时直接透传。
+// 其他错误只作为 raw error 记录到日志, 不返回给调用方, 避免泄露存储或者校验失败的细节
+func validated(principal *coreContext.Principal, err error) (*coreContext.Principal, errors.Error) {
+	if err != nil {
+		if eerr, ok := err.(errors.Error); ok {
+			return nil, eerr
+		}
+		return nil, errors.New(err, code.InvalidCredentialsErrCode, "credentials rejected")
+	}
+	if principal == nil {
+		return nil, errors.New(nil, code.InvalidCredentialsErrCode, "credentials rejected")
+	}
+	return principal, nil
+}
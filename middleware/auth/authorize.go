@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/pkg/config"
+)
+
+const permissionWildcard = "*"
+
+// Requirement 路由声明的权限要求
+type Requirement struct {
+	Method string
+	Route  string
+	// Permissions 需要拥有所有权限
+	Permissions []string
+	// Roles 需要拥有其中一个角色
+	Roles []string
+}
+
+// Authorizer 鉴权策略, 允许访问时返回 nil
+type Authorizer interface {
+	Authorize(ctx coreContext.Context, principal *coreContext.Principal, r Requirement) errors.Error
+}
+
+// AuthorizerFunc 函数形式的 Authorizer
+type AuthorizerFunc func(ctx coreContext.Context, principal *coreContext.Principal, r Requirement) errors.Error
+
+func (f AuthorizerFunc) Authorize(ctx coreContext.Context, principal *coreContext.Principal, r Requirement) errors.Error {
+	return f(ctx, principal, r)
+}
+
+// AuditEvent 鉴权拒绝的审计事件
+type AuditEvent struct {
+	TraceID     string
+	Method      string
+	Route       string
+	PrincipalID string
+	// Authenticator 认证方式
+	Authenticator string
+	Roles         []string
+	Requirement   Requirement
+	Reason        string
+}
+
+// Auditor 记录鉴权拒绝事件
+type Auditor func(ctx coreContext.Context, event AuditEvent)
+
+var (
+	authzMu sync.RWMutex
+	// authorizer 默认使用没有任何角色配置的 RBAC, 只有 Principal 自身的权限生效
+	authorizer Authorizer = NewRBAC(nil)
+	auditor    Auditor    = logAudit
+)
+
+// SetAuthorizer 设置鉴权策略, 默认使用 RBAC
+func SetAuthorizer(a Authorizer) {
+	authzMu.Lock()
+	defer authzMu.Unlock()
+	authorizer = a
+}
+
+// SetAuditor 设置鉴权拒绝的审计方式, 默认写日志
+func SetAuditor(a Auditor) {
+	authzMu.Lock()
+	defer authzMu.Unlock()
+	auditor = a
+}
+
+// Authorize 校验当前 Principal 是否满足路由的权限要求, 没有要求时直接通过。拒绝时记录审计事件
+func Authorize(ctx coreContext.Context, r Requirement) errors.Error {
+	if len(r.Permissions) == 0 && len(r.Roles) == 0 {
+		return nil
+	}
+	authzMu.RLock()
+	a, audit := authorizer, auditor
+	authzMu.RUnlock()
+
+	principal := ctx.Principal()
+	var err errors.Error
+	if principal == nil {
+		err = errors.New(nil, code.UnauthenticatedErrCode)
+	} else {
+		err = a.Authorize(ctx, principal, r)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if audit != nil {
+		event := AuditEvent{
+			TraceID:     ctx.GetRequestID(),
+			Method:      r.Method,
+			Route:       r.Route,
+			Requirement: r,
+			Reason:      err.Message(),
+		}
+		if principal != nil {
+			event.PrincipalID = principal.ID
+			event.Authenticator = principal.Authenticator
+			event.Roles = principal.Roles
+		}
+		audit(ctx, event)
+	}
+	return err
+}
+
+func logAudit(ctx coreContext.Context, e AuditEvent) {
+	ctx.Log().Infof("auth audit: access denied. method: %s, route: %s, principal: %s, authenticator: %s, roles: %v, "+
+		"required permissions: %v, required roles: %v, reason: %s",
+		e.Method, e.Route, e.PrincipalID, e.Authenticator, e.Roles, e.Requirement.Permissions, e.Requirement.Roles, e.Reason)
+}
+
+// RBACConfig 角色对应的权限, 权限支持通配符: * 表示所有权限, order:* 表示 order: 开头的所有权限
+type RBACConfig struct {
+	Roles map[string][]string `mapstructure:"roles"`
+}
+
+// RBAC 基于角色的鉴权。Principal 的权限为自身的 Permissions 加上所有角色的权限, 角色名不区分大小写
+type RBAC struct {
+	roles map[string][]string
+}
+
+func NewRBAC(roles map[string][]string) *RBAC {
+	r := &RBAC{roles: make(map[string][]string, len(roles))}
+	for role, perms := range roles {
+		role = strings.ToLower(role)
+		r.roles[role] = append(r.roles[role], perms...)
+	}
+	return r
+}
+
+// LoadRBAC 从配置中加载角色权限, key 为配置路径, 例如 rbac
+//
+//	rbac:
+//	  roles:
+//	    admin: ["*"]
+//	    editor: ["order:read", "order:write"]
+func LoadRBAC(key string) (*RBAC, error) {
+	conf := RBACConfig{}
+	if err := config.Unmarshal(key, &conf); err != nil {
+		return nil, fmt.Errorf("load rbac config %s: %w", key, err)
+	}
+	return NewRBAC(conf.Roles), nil
+}
+
+func (r *RBAC) Authorize(ctx coreContext.Context, principal *coreContext.Principal, req Requirement) errors.Error {
+	if len(req.Roles) > 0 && !hasAnyRole(principal.Roles, req.Roles) {
+		return errors.New(nil, code.PermissionDeniedErrCode, "role "+strings.Join(req.Roles, "|"))
+	}
+	for _, perm := range req.Permissions {
+		if !r.HasPermission(principal, perm) {
+			return errors.New(nil, code.PermissionDeniedErrCode, perm)
+		}
+	}
+	return nil
+}
+
+// HasPermission Principal 是否拥有权限
+func (r *RBAC) HasPermission(principal *coreContext.Principal, perm string) bool {
+	for _, granted := range principal.Permissions {
+		if matchPermission(granted, perm) {
+			return true
+		}
+	}
+	for _, role := range principal.Roles {
+		for _, granted := range r.roles[strings.ToLower(role)] {
+			if matchPermission(granted, perm) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAnyRole(roles, required []string) bool {
+	for _, role := range roles {
+		for _, r := range required {
+			if strings.EqualFold(role, r) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPermission granted 为 * 或者以 :* 结尾时按前缀匹配
+func matchPermission(granted, perm string) bool {
+	if granted == permissionWildcard || granted == perm {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(granted, permissionWildcard); ok && strings.HasSuffix(prefix, ":") {
+		return strings.HasPrefix(perm, prefix)
+	}
+	return false
+}
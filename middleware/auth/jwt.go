@@ -0,0 +1,61 @@
+package auth
+
+import (
+	osErr "errors"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/pkg/jwt"
+)
+
+const NameJWT = "jwt"
+
+// NewJWT Authorization: Bearer <jwt>。Principal.ID 为 sub, Name 为 name, Roles 为 roles, Claims 为 token 中的所有 claims
+func NewJWT(verifier *jwt.Verifier) Authenticator {
+	return &jwtAuthenticator{verifier: verifier}
+}
+
+type jwtAuthenticator struct {
+	verifier *jwt.Verifier
+}
+
+func (j *jwtAuthenticator) Name() string {
+	return NameJWT
+}
+
+func (j *jwtAuthenticator) Challenge() string {
+	return "Bearer"
+}
+
+func (j *jwtAuthenticator) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	token, ok := authorization(ctx, "Bearer")
+	if !ok {
+		return nil, nil
+	}
+	claims, err := j.verifier.Verify(token)
+	if err != nil {
+		if osErr.Is(err, jwt.ErrExpired) {
+			return nil, errors.New(err, code.TokenExpiredErrCode)
+		}
+		// kid, alg 等校验细节只记录在服务端日志, 避免帮助调用方探测 key
+		ctx.Log().Infof("jwt: verify token failed. err: %s", err)
+		return nil, errors.New(err, code.InvalidCredentialsErrCode, "invalid token")
+	}
+	name, _ := claims["name"].(string)
+	return &coreContext.Principal{
+		ID:     claims.Subject(),
+		Name:   name,
+		Roles:  claims.Strings("roles"),
+		Claims: claims,
+	}, nil
+}
+
+// JWTClaims 获取 jwt 认证通过的 claims
+func JWTClaims(ctx coreContext.Context) (jwt.Claims, bool) {
+	p := ctx.Principal()
+	if p == nil || p.Authenticator != NameJWT {
+		return nil, false
+	}
+	return jwt.Claims(p.Claims), true
+}
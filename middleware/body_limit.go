@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const maxBodySizeKey = "go-api-component/max-body-size"
+
+var maxBodySize atomic.Int64
+
+// SetMaxBodySize 设置全局的 body 大小限制, 用于没有指定 Route.MaxBodySize 的路由, 单位字节, 小于等于 0 时不限制。
+// gin engine 上使用了 MaxBodySizeHandler 时优先使用 engine 的设置
+func SetMaxBodySize(n int64) {
+	maxBodySize.Store(n)
+}
+
+// MaxBodySize 获取 SetMaxBodySize 设置的 body 大小限制
+func MaxBodySize() int64 {
+	return maxBodySize.Load()
+}
+
+// MaxBodySizeHandler 为当前 gin engine 设置 body 大小限制, 只对该 engine 生效, 多个 server 之间互不影响。
+// 需要在注册路由之前 Use, server.Server.MaxBodySize 不为 0 时由 server 设置
+func MaxBodySizeHandler(n int64) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Set(maxBodySizeKey, n)
+		g.Next()
+	}
+}
+
+// limitBody limit 为 0 时使用 engine 或者全局的 MaxBodySize, 小于 0 时不限制。
+// Content-Length 超过限制时直接返回错误, 否则读取 body 超过限制时返回 http.MaxBytesError
+func limitBody(g *gin.Context, limit int64) errors.Error {
+	if limit == 0 {
+		if v, exists := g.Get(maxBodySizeKey); exists {
+			limit, _ = v.(int64)
+		} else {
+			limit = MaxBodySize()
+		}
+	}
+	if limit <= 0 || g.Request.Body == nil || g.Request.Body == http.NoBody {
+		return nil
+	}
+	if g.Request.ContentLength > limit {
+		return errors.New(nil, code.RequestBodyTooLargeErrCode, limit)
+	}
+	g.Request.Body = http.MaxBytesReader(g.Writer, g.Request.Body, limit)
+	return nil
+}
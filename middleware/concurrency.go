@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
+	"github.com/rentiansheng/go-api-component/pkg/metrics"
+)
+
+const concurrencyLimiterKey = "go-api-component/concurrency-limiter"
+
+var (
+	concurrencyLimiter atomic.Pointer[concurrency.Limiter]
+
+	httpRequestsShedTotal = metrics.NewCounterVec("http_requests_shed_total",
+		"Total number of HTTP requests rejected by the concurrency limiter.", "method", "route", "priority")
+	concurrencyLimitGauge = metrics.NewGaugeVec("http_concurrency_limit",
+		"Current adaptive concurrency limit.")
+)
+
+// SetConcurrencyLimiter 设置全局的自适应并发限制, Web 注册的路由在处理前获取许可, 超过限制时返回 ServerOverloadedErrCode。
+// l 为 nil 时关闭。gin engine 上使用了 ConcurrencyLimiterHandler 时优先使用 engine 的并发限制
+func SetConcurrencyLimiter(l *concurrency.Limiter) {
+	concurrencyLimiter.Store(l)
+}
+
+// ConcurrencyLimiter 获取 SetConcurrencyLimiter 设置的并发限制, 没有设置时返回 nil
+func ConcurrencyLimiter() *concurrency.Limiter {
+	return concurrencyLimiter.Load()
+}
+
+// ConcurrencyLimiterHandler 为当前 gin engine 设置并发限制, 只对该 engine 生效, 多个 server 之间互不影响。
+// 需要在注册路由之前 Use, server.Server.Concurrency 开启时由 server 设置
+func ConcurrencyLimiterHandler(l *concurrency.Limiter) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Set(concurrencyLimiterKey, l)
+		g.Next()
+	}
+}
+
+// acquireConcurrency 没有设置并发限制时 release 为 nil
+func acquireConcurrency(g *gin.Context, route string, p concurrency.Priority) (release func(dropped bool), err errors.Error) {
+	l := concurrencyLimiter.Load()
+	if v, exists := g.Get(concurrencyLimiterKey); exists {
+		l, _ = v.(*concurrency.Limiter)
+	}
+	if l == nil {
+		return nil, nil
+	}
+	release, ok := l.Acquire(p)
+	concurrencyLimitGauge.WithLabelValues().Set(float64(l.Limit()))
+	if !ok {
+		httpRequestsShedTotal.WithLabelValues(g.Request.Method, route, p.String()).Inc()
+		return nil, errors.New(nil, code.ServerOverloadedErrCode)
+	}
+	return release, nil
+}
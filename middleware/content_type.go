@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// checkContentType consumes 不为空时请求 body 的 Content-Type 需要在 consumes 中, 否则返回 UnsupportedMediaTypeErrCode。
+// produces 不为空且请求有 Accept 时至少需要接受其中一个类型, 否则返回 NotAcceptableErrCode
+func checkContentType(r *http.Request, consumes, produces []ContentType) errors.Error {
+	if len(consumes) > 0 && hasBody(r) {
+		ct := r.Header.Get("Content-Type")
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || !containsMediaType(consumes, mediaType) {
+			return errors.New(err, code.UnsupportedMediaTypeErrCode, ct, joinContentTypes(consumes))
+		}
+	}
+	if accept := r.Header.Get("Accept"); len(produces) > 0 && accept != "" {
+		if negotiate(accept, contentTypeStrings(produces)) == "" {
+			return errors.New(nil, code.NotAcceptableErrCode, accept, joinContentTypes(produces))
+		}
+	}
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.ContentLength > 0 || (r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody)
+}
+
+// containsMediaType types 中可以使用 type/* 或者 */*
+func containsMediaType(types []ContentType, mediaType string) bool {
+	for _, t := range types {
+		if mediaRangeMatch(strings.ToLower(string(t)), mediaType) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func joinContentTypes(types []ContentType) string {
+	return strings.Join(contentTypeStrings(types), ", ")
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []acceptRange {
+	ranges := make([]acceptRange, 0, 4)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// mediaRangeMatch 返回匹配的精确程度, 0 为不匹配, 3 为完全相同
+func mediaRangeMatch(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 3
+	case mediaRange == "*/*":
+		return 1
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 2
+	}
+	return 0
+}
+
+// negotiate 按 Accept 从 offers 中选择 q 最大的类型, q 相同时选择 offers 中靠前的。
+// 每个 offer 使用最精确匹配的 media range 的 q, 都不可接受时返回空字符串
+func negotiate(accept string, offers []string) string {
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		mediaType := strings.ToLower(offer)
+		q, specificity := 0.0, 0
+		for _, r := range ranges {
+			if s := mediaRangeMatch(r.mediaType, mediaType); s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
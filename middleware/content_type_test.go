@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml"}
+	tests := []struct {
+		accept   string
+		expected string
+	}{
+		{"application/json", "application/json"},
+		{"application/xml", "application/xml"},
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"text/html, application/xml;q=0.9, */*;q=0.1", "application/xml"},
+		{"*/*, application/json;q=0", "application/xml"},
+		{"application/xml;q=0.5, application/json;q=0.8", "application/json"},
+		{"text/html", ""},
+		{"application/*;q=0", ""},
+		{"invalid;;", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, negotiate(tt.accept, offers), tt.accept)
+	}
+}
@@ -0,0 +1,148 @@
+package context
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v3"
+
+	"github.com/rentiansheng/go-api-component/middleware/context/decode"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// BodyDecoder 将请求 body 解析到 obj, body 不为空
+type BodyDecoder func(body []byte, obj interface{}) error
+
+var (
+	bodyDecodersMu sync.RWMutex
+	bodyDecoders   = map[string]BodyDecoder{}
+)
+
+func init() {
+	RegisterBodyDecoder(MIMEJSON, decodeJSONBody)
+	RegisterBodyDecoder(MIMEXML, decodeXMLBody)
+	RegisterBodyDecoder(MIMEXML2, decodeXMLBody)
+	RegisterBodyDecoder(MIMEYAML, decodeYAMLBody)
+	RegisterBodyDecoder("application/yaml", decodeYAMLBody)
+	RegisterBodyDecoder(MIMETOML, decodeTOMLBody)
+	RegisterBodyDecoder(MIMEMSGPACK, decodeMsgPackBody)
+	RegisterBodyDecoder(MIMEMSGPACK2, decodeMsgPackBody)
+	RegisterBodyDecoder(MIMEPROTOBUF, decodeProtoBufBody)
+}
+
+// RegisterBodyDecoder 注册 Content-Type 对应的 body 解析方式, 已经注册时替换。
+// form 和 multipart 使用 decode.Form 解析, 不能替换
+func RegisterBodyDecoder(mediaType string, d BodyDecoder) {
+	bodyDecodersMu.Lock()
+	defer bodyDecodersMu.Unlock()
+	bodyDecoders[strings.ToLower(mediaType)] = d
+}
+
+// getBodyDecoder 没有注册时 application/*+json, application/*+xml 使用 json, xml 的解析方式
+func getBodyDecoder(mediaType string) (BodyDecoder, bool) {
+	bodyDecodersMu.RLock()
+	defer bodyDecodersMu.RUnlock()
+	if d, ok := bodyDecoders[mediaType]; ok {
+		return d, true
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		d, ok := bodyDecoders[MIMEJSON]
+		return d, ok
+	case strings.HasSuffix(mediaType, "+xml"):
+		d, ok := bodyDecoders[MIMEXML]
+		return d, ok
+	}
+	return nil, false
+}
+
+func supportedBodyTypes() string {
+	bodyDecodersMu.RLock()
+	defer bodyDecodersMu.RUnlock()
+	types := []string{MIMEPOSTForm, MIMEMultipartPOSTForm}
+	for t := range bodyDecoders {
+		types = append(types, t)
+	}
+	sort.Strings(types[2:])
+	return strings.Join(types, ", ")
+}
+
+// decodeBody 根据 Content-Type 解析 body。没有 Content-Type 或者 body 为空时不解析,
+// 没有对应的解析方式时返回 UnsupportedMediaTypeErrCode
+func decodeBody(req *http.Request, obj interface{}) error {
+	ct := req.Header.Get("Content-Type")
+	if ct == "" || req.Body == nil {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return errors.New(err, code.UnsupportedMediaTypeErrCode, ct, supportedBodyTypes())
+	}
+	if mediaType == MIMEPOSTForm || mediaType == MIMEMultipartPOSTForm {
+		return wrapBodyErr(decode.Form(req, obj))
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return wrapBodyErr(err)
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	d, ok := getBodyDecoder(mediaType)
+	if !ok {
+		return errors.New(nil, code.UnsupportedMediaTypeErrCode, ct, supportedBodyTypes())
+	}
+	return d(body, obj)
+}
+
+func decodeJSONBody(body []byte, obj interface{}) error {
+	if err := checkJSONBody(body); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if EnableDecoderUseNumber {
+		decoder.UseNumber()
+	}
+	if EnableDecoderDisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(obj)
+}
+
+func decodeXMLBody(body []byte, obj interface{}) error {
+	return xml.Unmarshal(body, obj)
+}
+
+func decodeYAMLBody(body []byte, obj interface{}) error {
+	return yaml.Unmarshal(body, obj)
+}
+
+func decodeTOMLBody(body []byte, obj interface{}) error {
+	return toml.Unmarshal(body, obj)
+}
+
+func decodeMsgPackBody(body []byte, obj interface{}) error {
+	return codec.NewDecoderBytes(body, new(codec.MsgpackHandle)).Decode(obj)
+}
+
+// decodeProtoBufBody obj 需要实现 proto.Message
+func decodeProtoBufBody(body []byte, obj interface{}) error {
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf body requires a proto.Message target, got %T", obj)
+	}
+	return proto.Unmarshal(body, msg)
+}
@@ -0,0 +1,96 @@
+package context
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+type bodyTarget struct {
+	Name string `json:"name" xml:"name" yaml:"name" toml:"name" form:"name"`
+	Age  int    `json:"age" xml:"age" yaml:"age" toml:"age" form:"age"`
+}
+
+func newBodyRequest(contentType string, body []byte) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req
+}
+
+func TestDecodeBody(t *testing.T) {
+	var msgpack []byte
+	require.NoError(t, codec.NewEncoderBytes(&msgpack, new(codec.MsgpackHandle)).Encode(map[string]interface{}{"name": "go", "age": 10}))
+
+	tests := []struct {
+		contentType string
+		body        []byte
+	}{
+		{"application/json; charset=utf-8", []byte(`{"name":"go","age":10}`)},
+		{"application/problem+json", []byte(`{"name":"go","age":10}`)},
+		{"application/xml", []byte(`<target><name>go</name><age>10</age></target>`)},
+		{"text/xml", []byte(`<target><name>go</name><age>10</age></target>`)},
+		{"application/x-yaml", []byte("name: go\nage: 10\n")},
+		{"application/toml", []byte("name = 'go'\nage = 10\n")},
+		{"application/x-msgpack", msgpack},
+		{"application/msgpack", msgpack},
+		{"application/x-www-form-urlencoded", []byte("name=go&age=10")},
+	}
+	for _, tt := range tests {
+		var target bodyTarget
+		err := decodeBody(newBodyRequest(tt.contentType, tt.body), &target)
+		assert.NoError(t, err, tt.contentType)
+		assert.Equal(t, bodyTarget{Name: "go", Age: 10}, target, tt.contentType)
+	}
+
+	// 空 body 和没有 Content-Type 时不解析
+	var target bodyTarget
+	assert.NoError(t, decodeBody(newBodyRequest("text/plain", nil), &target))
+	assert.NoError(t, decodeBody(newBodyRequest("", []byte("hello")), &target))
+
+	err := decodeBody(newBodyRequest("text/plain", []byte("hello")), &target)
+	require.Error(t, err)
+	meErr, ok := err.(errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, code.UnsupportedMediaTypeErrCode, meErr.Code())
+	assert.Contains(t, meErr.Message(), "application/x-protobuf")
+}
+
+func TestDecodeBody_ProtoBuf(t *testing.T) {
+	body, err := proto.Marshal(wrapperspb.String("go"))
+	require.NoError(t, err)
+
+	msg := &wrapperspb.StringValue{}
+	assert.NoError(t, decodeBody(newBodyRequest(MIMEPROTOBUF, body), msg))
+	assert.Equal(t, "go", msg.GetValue())
+
+	var target bodyTarget
+	assert.Error(t, decodeBody(newBodyRequest(MIMEPROTOBUF, body), &target))
+}
+
+func TestRegisterBodyDecoder(t *testing.T) {
+	RegisterBodyDecoder("text/csv", func(body []byte, obj interface{}) error {
+		obj.(*bodyTarget).Name = string(body)
+		return nil
+	})
+	defer func() {
+		bodyDecodersMu.Lock()
+		delete(bodyDecoders, "text/csv")
+		bodyDecodersMu.Unlock()
+	}()
+
+	var target bodyTarget
+	assert.NoError(t, decodeBody(newBodyRequest("text/csv", []byte("go")), &target))
+	assert.Equal(t, "go", target.Name)
+}
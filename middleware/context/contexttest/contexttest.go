@@ -0,0 +1,19 @@
+// Package contexttest 提供测试中使用的 Contexts
+package contexttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+)
+
+// NewContext 使用 req 创建 Contexts, 响应写入返回的 ResponseRecorder
+func NewContext(req *http.Request) (coreContext.Contexts, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	return coreContext.NewContext(c), w
+}
@@ -1,8 +1,7 @@
 package context
 
 import (
-	"bytes"
-	"encoding/json"
+	stdErrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 
 	"github.com/rentiansheng/go-api-component/middleware/context/decode"
 	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
 )
 
 // EnableDecoderUseNumber is used to call the UseNumber method on the JSON
@@ -58,39 +58,16 @@ type DefaultI interface {
 	Default()
 }
 
-// autoDecode  自动适配需要解析方式。已经支持自动解析query string, post(application/x-www-form-urlencoded,json)
-// 默认是先解析 query string ,然后根据 http content type 解析 合并数据。默认是json 方式
+// autoDecode  自动适配需要解析方式。已经支持自动解析query string, form, multipart 和 RegisterBodyDecoder 注册的类型
+// 默认是先解析 query string ,然后根据 http content type 解析 合并数据
 func autoDecode(req *http.Request, urlParams map[string][]string, obj interface{}) error {
 
 	if err := decode.Query(req, obj); err != nil {
 		return err
 	}
 
-	contextTypes := strings.Split(req.Header.Get("Content-Type"), ";")
-	switch contextTypes[0] {
-	case MIMEPOSTForm:
-		if err := decode.Form(req, obj); err != nil {
-			return err
-		}
-	case MIMEJSON:
-		reqBodyBytes, err := io.ReadAll(req.Body)
-		if err != nil {
-			return err
-		}
-		if len(reqBodyBytes) > 0 {
-			decoder := json.NewDecoder(bytes.NewReader(reqBodyBytes))
-			if EnableDecoderUseNumber {
-				decoder.UseNumber()
-			}
-			if EnableDecoderDisallowUnknownFields {
-				decoder.DisallowUnknownFields()
-			}
-
-			if err := decoder.Decode(obj); err != nil {
-				return err
-			}
-		}
-
+	if err := decodeBody(req, obj); err != nil {
+		return err
 	}
 	newHeader := make(map[string][]string, 0)
 	for c, v := range req.Header {
@@ -113,6 +90,9 @@ func autoDecode(req *http.Request, urlParams map[string][]string, obj interface{
 	if err := validateStruct(obj); err != nil {
 		return err
 	}
+	if err := validateFiles(obj); err != nil {
+		return err
+	}
 
 	if valid, ok := obj.(ValidateRawI); ok {
 		if err := valid.Validate(); err != nil {
@@ -275,21 +255,12 @@ country_code	iso3166_1_alpha2|iso3166_1_alpha3|iso3166_1_alpha_numeric
 func decodeJSON(r io.Reader, obj interface{}) error {
 	reqBodyBytes, err := io.ReadAll(r)
 	if err != nil {
-		return err
+		return wrapBodyErr(err)
 	}
 	if len(reqBodyBytes) > 0 {
-		decoder := json.NewDecoder(bytes.NewReader(reqBodyBytes))
-		if EnableDecoderUseNumber {
-			decoder.UseNumber()
-		}
-		if EnableDecoderDisallowUnknownFields {
-			decoder.DisallowUnknownFields()
-		}
-
-		if err := decoder.Decode(obj); err != nil {
+		if err := decodeJSONBody(reqBodyBytes, obj); err != nil {
 			return err
 		}
-
 	}
 
 	if defaultSet, ok := obj.(DefaultI); ok {
@@ -312,6 +283,16 @@ func decodeJSON(r io.Reader, obj interface{}) error {
 	return nil
 }
 
+// validateFiles 校验 file tag, 失败时返回 InvalidFileErrCode
+func validateFiles(obj interface{}) error {
+	err := decode.ValidateFiles(obj)
+	var fileErr *decode.FileError
+	if stdErrors.As(err, &fileErr) {
+		return errors.New(err, code.InvalidFileErrCode, fileErr.Field, fileErr.Err.Error())
+	}
+	return err
+}
+
 func validateStruct(obj interface{}) error {
 	if obj == nil {
 		return nil
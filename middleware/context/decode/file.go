@@ -0,0 +1,206 @@
+package decode
+
+import (
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+func isFileType(t reflect.Type) bool {
+	return t == fileHeaderType || t == fileHeaderSliceType
+}
+
+// fileSource multipart 中的文件, 只设置 *multipart.FileHeader 和 []*multipart.FileHeader 类型的字段
+type fileSource map[string][]*multipart.FileHeader
+
+var _ setter = fileSource(nil)
+
+func (files fileSource) TrySet(value reflect.Value, field reflect.StructField, key string, opt setOptions) (isSet bool, err error) {
+	fhs := files[key]
+	if len(fhs) == 0 {
+		return false, nil
+	}
+	switch value.Type() {
+	case fileHeaderType:
+		value.Set(reflect.ValueOf(fhs[0]))
+	case fileHeaderSliceType:
+		value.Set(reflect.ValueOf(fhs))
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// FileError 文件字段不满足 file tag 的限制
+type FileError struct {
+	// Field form tag 中的名称
+	Field string
+	Err   error
+}
+
+func (e *FileError) Error() string {
+	return fmt.Sprintf("file field %s: %v", e.Field, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+// fileRule file tag, 例如 `file:"max_size=2MB,max_count=3,types=image/png|image/jpeg|image/*"`
+type fileRule struct {
+	maxSize  int64
+	maxCount int
+	types    []string
+}
+
+func parseFileRule(tag string) (fileRule, error) {
+	var rule fileRule
+	for _, opt := range strings.Split(tag, ",") {
+		k, v := head(strings.TrimSpace(opt), "=")
+		var err error
+		switch k {
+		case "":
+		case "max_size":
+			rule.maxSize, err = parseSize(v)
+		case "max_count":
+			rule.maxCount, err = strconv.Atoi(v)
+		case "types":
+			for _, t := range strings.Split(v, "|") {
+				if t = strings.TrimSpace(t); t != "" {
+					rule.types = append(rule.types, strings.ToLower(t))
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown file rule %q", k)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+	return rule, nil
+}
+
+// parseSize 支持 B, KB, MB, GB, 没有单位时为字节
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSuffix(s, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+func (r fileRule) check(files []*multipart.FileHeader) error {
+	if r.maxCount > 0 && len(files) > r.maxCount {
+		return fmt.Errorf("too many files, max count: %d", r.maxCount)
+	}
+	for _, fh := range files {
+		if r.maxSize > 0 && fh.Size > r.maxSize {
+			return fmt.Errorf("file %s is too large, max size: %d bytes", fh.Filename, r.maxSize)
+		}
+		if len(r.types) > 0 {
+			mtype, err := sniff(fh)
+			if err != nil {
+				return fmt.Errorf("read file %s: %w", fh.Filename, err)
+			}
+			if !r.allowType(mtype) {
+				return fmt.Errorf("file %s type %s is not allowed", fh.Filename, mtype.String())
+			}
+		}
+	}
+	return nil
+}
+
+// sniff 根据文件内容判断类型, 不使用客户端提供的 Content-Type
+func sniff(fh *multipart.FileHeader) (*mimetype.MIME, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return mimetype.DetectReader(f)
+}
+
+func (r fileRule) allowType(mtype *mimetype.MIME) bool {
+	base, _ := head(mtype.String(), ";")
+	for _, t := range r.types {
+		if mtype.Is(t) || (strings.HasSuffix(t, "/*") && strings.HasPrefix(base, strings.TrimSuffix(t, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFiles 校验 obj 中有 file tag 的文件字段, 失败时返回 *FileError
+func ValidateFiles(obj interface{}) error {
+	return validateFiles(reflect.ValueOf(obj))
+}
+
+func validateFiles(value reflect.Value) error {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	t := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		fv := value.Field(i)
+		if !isFileType(sf.Type) {
+			if err := validateFiles(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		tag, ok := sf.Tag.Lookup("file")
+		if !ok {
+			continue
+		}
+		name, _ := head(sf.Tag.Get("form"), ",")
+		if name == "" {
+			name = sf.Name
+		}
+		rule, err := parseFileRule(tag)
+		if err != nil {
+			return &FileError{Field: name, Err: err}
+		}
+		var files []*multipart.FileHeader
+		if sf.Type == fileHeaderType {
+			if !fv.IsNil() {
+				files = []*multipart.FileHeader{fv.Interface().(*multipart.FileHeader)}
+			}
+		} else {
+			files = fv.Interface().([]*multipart.FileHeader)
+		}
+		if err := rule.check(files); err != nil {
+			return &FileError{Field: name, Err: err}
+		}
+	}
+	return nil
+}
@@ -35,6 +35,9 @@ func Form(req *http.Request, obj interface{}) error {
 	if err := mapForm(obj, req.Form); err != nil {
 		return err
 	}
+	if req.MultipartForm != nil && len(req.MultipartForm.File) > 0 {
+		return mappingByPtr(obj, fileSource(req.MultipartForm.File), "form")
+	}
 	return nil
 }
 
@@ -99,6 +102,11 @@ func mapping(value reflect.Value, field reflect.StructField, setter setter, tag
 		return false, nil
 	}
 
+	// 文件字段只由 fileSource 设置, 不展开 multipart.FileHeader
+	if isFileType(value.Type()) {
+		return tryToSetValue(value, field, setter, tag)
+	}
+
 	vKind := value.Kind()
 
 	if vKind == reflect.Ptr {
@@ -181,6 +189,9 @@ func tryToSetValue(value reflect.Value, field reflect.StructField, setter setter
 }
 
 func setByForm(value reflect.Value, field reflect.StructField, form map[string][]string, tagValue string, opt setOptions) (isSet bool, err error) {
+	if isFileType(value.Type()) {
+		return false, nil
+	}
 	vs, ok := form[tagValue]
 	if !ok && !opt.isDefaultExists {
 		return false, nil
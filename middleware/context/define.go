@@ -38,6 +38,14 @@ type Contexts interface {
 	SetPageResponse(val interface{})
 	SetResponseFile(fileName string, content *bytes.Buffer)
 	GetResponseFile() (fileName string, content *bytes.Buffer, exists bool)
+	// SetResponseStream 流式返回文件, 支持 Range 和条件请求, 不需要把文件全部读到内存中
+	SetResponseStream(stream ResponseStream)
+	// SetResponseFilePath 流式返回本地文件, 文件不存在时返回 StreamFileNotFoundErrCode, 其他错误和不是普通文件时返回 StreamFileErrCode
+	SetResponseFilePath(path string, inline bool) errors.Error
+	GetResponseStream() (stream ResponseStream, exists bool)
+	// SSE 开始 server-sent events 响应, 写出响应头后 Wrapper 不再返回 json
+	SSE() (*EventStream, errors.Error)
+	GetEventStream() (stream *EventStream, exists bool)
 	GetExtraResponse() map[string]interface{}
 
 	SetRawResponse(typ string, body []byte)
@@ -49,6 +57,9 @@ type Contexts interface {
 	GetData() interface{}
 
 	HTTPBody() ([]byte, error)
+	// Session 当前请求的 session, 没有启用 session 组件时返回 nil
+	Session() Session
+	SetSession(s Session)
 
 	Context
 }
@@ -60,6 +71,9 @@ type Context interface {
 	Request() *http.Request
 
 	GetRequestID() string
+	// Principal 认证通过的调用方, 未认证时返回 nil
+	Principal() *Principal
+	SetPrincipal(p *Principal)
 
 	Log() Log
 	Error() errImpl
@@ -33,7 +33,12 @@ type ginContext struct {
 		body   []byte
 		exists bool
 	}
-	cancelFn func()
+	responseStream struct {
+		stream ResponseStream
+		exists bool
+	}
+	eventStream *EventStream
+	cancelFn    func()
 
 	meErr errImpl
 }
@@ -76,7 +81,7 @@ func (g *ginContext) HTTPBody() ([]byte, error) {
 	bodyBytes, err := ioutil.ReadAll(g.c.Request.Body)
 	if err != nil {
 		g.Log().Errorf("io read request.Body fail, %+v", err)
-		return nil, g.Error().LegacyWrapCode(code.JSONDecodeErrCode, err)
+		return nil, g.Error().LegacyWrapCode(code.JSONDecodeErrCode, wrapBodyErr(err))
 	}
 	g.c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 	return bodyBytes, nil
@@ -129,17 +134,44 @@ func (c *ginContext) clone() *ginContext {
 	ctx := osCtx.WithValue(c.ctx, "_", c.Value("_"))
 	// copy all fields
 	return &ginContext{
-		ctx:           ctx,
-		c:             c.c,
-		extraResponse: c.extraResponse,
-		pageResponse:  c.pageResponse,
-		data:          c.data,
-		requestID:     c.requestID,
-		responseFile:  c.responseFile,
-		rawResponse:   c.rawResponse,
-		cancelFn:      c.cancelFn,
-		meErr:         c.meErr,
+		ctx:            ctx,
+		c:              c.c,
+		extraResponse:  c.extraResponse,
+		pageResponse:   c.pageResponse,
+		data:           c.data,
+		requestID:      c.requestID,
+		responseFile:   c.responseFile,
+		rawResponse:    c.rawResponse,
+		responseStream: c.responseStream,
+		eventStream:    c.eventStream,
+		cancelFn:       c.cancelFn,
+		meErr:          c.meErr,
+	}
+}
+
+// DetachGinContext 返回在其他 goroutine 中执行 handler 使用的 Contexts, 使用 gin.Context 的副本, 响应写入 w。
+// 请求结束后 gin 会复用原来的 gin.Context, 超时后继续运行的 handler 不能再访问它。handler 按时返回后使用 AttachGinContext 恢复
+func DetachGinContext(ctx Contexts, w gin.ResponseWriter) Contexts {
+	g, ok := ctx.(*ginContext)
+	if !ok {
+		return ctx
+	}
+	cp := *g
+	cp.c = g.c.Copy()
+	cp.c.Writer = w
+	return &cp
+}
+
+// AttachGinContext 恢复为请求的 gin.Context, handler 在副本上设置的 Keys 复制回请求的 gin.Context
+func AttachGinContext(ctx Contexts, c *gin.Context) {
+	g, ok := ctx.(*ginContext)
+	if !ok || g.c == c {
+		return
+	}
+	for k, v := range g.c.Keys {
+		c.Set(k, v)
 	}
+	g.c = c
 }
 
 // SubContext implements Contexts.
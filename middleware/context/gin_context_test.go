@@ -2,9 +2,11 @@ package context
 
 import (
 	"bytes"
+	"fmt"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"strings"
 	"testing"
 
@@ -361,3 +363,99 @@ func TestGinContext_IsDone(t *testing.T) {
 		}, 100, 10)
 	}
 }
+
+func newMultipartRequest(t *testing.T, fields map[string]string, files map[string][][]byte) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		assert.NoError(t, mw.WriteField(k, v))
+	}
+	for name, contents := range files {
+		for i, content := range contents {
+			// 客户端提供的 Content-Type 不可信, 统一写成 image/png
+			h := textproto.MIMEHeader{}
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename="f%d"`, name, i))
+			h.Set("Content-Type", "image/png")
+			part, err := mw.CreatePart(h)
+			assert.NoError(t, err)
+			_, _ = part.Write(content)
+		}
+	}
+	assert.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestGinContext_DecodeFiles(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	type upload struct {
+		Name        string                  `form:"name"`
+		Avatar      *multipart.FileHeader   `form:"avatar" file:"max_size=1KB,types=image/png|image/jpeg" validate:"required"`
+		Attachments []*multipart.FileHeader `form:"attachments" file:"max_count=2,types=image/*"`
+	}
+
+	_, c, _ := setupGinTest()
+	c.Request = newMultipartRequest(t, map[string]string{"name": "go"}, map[string][][]byte{
+		"avatar":      {png},
+		"attachments": {png, png},
+	})
+	var target upload
+	assert.Nil(t, NewGinContext(c).Decode(&target))
+	assert.Equal(t, "go", target.Name)
+	if assert.NotNil(t, target.Avatar) {
+		assert.Equal(t, int64(len(png)), target.Avatar.Size)
+	}
+	assert.Len(t, target.Attachments, 2)
+
+	tests := []struct {
+		name  string
+		files map[string][][]byte
+		err   string
+	}{
+		{"required", map[string][][]byte{"attachments": {png}}, "Avatar"},
+		{"too large", map[string][][]byte{"avatar": {append(png, make([]byte, 1024)...)}}, "field: avatar"},
+		{"sniffed type", map[string][][]byte{"avatar": {[]byte("hello world")}}, "text/plain"},
+		{"too many", map[string][][]byte{"avatar": {png}, "attachments": {png, png, png}}, "field: attachments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, c, _ := setupGinTest()
+			c.Request = newMultipartRequest(t, nil, tt.files)
+			var target upload
+			err := NewGinContext(c).Decode(&target)
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+// noFlushWriter 不支持 http.Flusher 的 ResponseWriter
+type noFlushWriter struct {
+	http.ResponseWriter
+}
+
+func TestGinContext_SSE(t *testing.T) {
+	_, c, w := setupGinTest()
+	c.Request = httptest.NewRequest("GET", "/events", nil)
+	ctx := NewGinContext(c)
+
+	es, err := ctx.SSE()
+	assert.Nil(t, err)
+	again, _ := ctx.SSE()
+	assert.Same(t, es, again)
+	assert.Error(t, es.Send(Event{ID: "1\n2"}))
+	assert.NoError(t, es.Send(Event{Event: "done"}))
+	es.Close()
+	assert.ErrorIs(t, es.Send(Event{Data: "x"}), ErrEventStreamClosed)
+	assert.Equal(t, "event: done\ndata: \n\n", w.Body.String())
+
+	gin.SetMode(gin.TestMode)
+	c, _ = gin.CreateTestContext(noFlushWriter{httptest.NewRecorder()})
+	c.Request = httptest.NewRequest("GET", "/events", nil)
+	_, err = NewGinContext(c).SSE()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, int32(1010), err.Code())
+	}
+}
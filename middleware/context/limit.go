@@ -0,0 +1,94 @@
+package context
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// JSONLimits 解析 json body 前检查的限制, 为 0 时不限制
+type JSONLimits struct {
+	// MaxDepth 对象和数组的最大嵌套层数
+	MaxDepth int `mapstructure:"max_depth"`
+	// MaxArrayLen 单个数组的最大元素个数
+	MaxArrayLen int `mapstructure:"max_array_len"`
+	// MaxStringLen 单个字符串(包括 key)转义前的最大字节数
+	MaxStringLen int `mapstructure:"max_string_len"`
+}
+
+// DecoderJSONLimits Decode, JSONDecode 解析 json 之前的检查, 超过限制时返回 JSONTooComplexErrCode
+var DecoderJSONLimits = JSONLimits{MaxDepth: 100}
+
+// Check 只扫描一遍 data, 不校验 json 格式。只有设置了 MaxArrayLen 时才按嵌套层数记录每个数组的元素个数,
+// 同时设置 MaxDepth 时占用的内存不超过 MaxDepth
+func (l JSONLimits) Check(data []byte) error {
+	if l.MaxDepth <= 0 && l.MaxArrayLen <= 0 && l.MaxStringLen <= 0 {
+		return nil
+	}
+	// commas 每一层中已经出现的逗号个数, 对象为 -1
+	var (
+		depth  int
+		commas []int
+	)
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; c {
+		case '"':
+			start := i + 1
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			if l.MaxStringLen > 0 && i-start > l.MaxStringLen {
+				return fmt.Errorf("string length exceeds %d", l.MaxStringLen)
+			}
+		case '{', '[':
+			depth++
+			if l.MaxDepth > 0 && depth > l.MaxDepth {
+				return fmt.Errorf("nesting depth exceeds %d", l.MaxDepth)
+			}
+			if l.MaxArrayLen > 0 {
+				n := -1
+				if c == '[' {
+					n = 0
+				}
+				commas = append(commas, n)
+			}
+		case '}', ']':
+			if depth > 0 {
+				depth--
+				if l.MaxArrayLen > 0 {
+					commas = commas[:depth]
+				}
+			}
+		case ',':
+			if n := len(commas); n > 0 && commas[n-1] >= 0 {
+				commas[n-1]++
+				if commas[n-1] >= l.MaxArrayLen {
+					return fmt.Errorf("array length exceeds %d", l.MaxArrayLen)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkJSONBody 使用 DecoderJSONLimits 检查 json body
+func checkJSONBody(body []byte) error {
+	if err := DecoderJSONLimits.Check(body); err != nil {
+		return errors.New(err, code.JSONTooComplexErrCode, err.Error())
+	}
+	return nil
+}
+
+// wrapBodyErr 读取 body 超过 http.MaxBytesReader 的限制时返回 RequestBodyTooLargeErrCode
+func wrapBodyErr(err error) error {
+	var maxErr *http.MaxBytesError
+	if stdErrors.As(err, &maxErr) {
+		return errors.New(err, code.RequestBodyTooLargeErrCode, maxErr.Limit)
+	}
+	return err
+}
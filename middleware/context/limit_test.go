@@ -0,0 +1,64 @@
+package context
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLimits_Check(t *testing.T) {
+	l := JSONLimits{MaxDepth: 3, MaxArrayLen: 3, MaxStringLen: 5}
+	tests := []struct {
+		body string
+		ok   bool
+	}{
+		{`{"a":[1,2,3],"b":{"c":"hello"}}`, true},
+		{`{"a":{"b":{"c":1}}}`, true},
+		{`{"a":{"b":{"c":[1]}}}`, false},
+		{`[1,2,3,4]`, false},
+		{`[[1,2,3],[4,5,6],[7,8,9]]`, true},
+		{`{"a":"hello!"}`, false},
+		{`{"a":"he\"l,[o"}`, false},
+		{`{"a":"\",[["}`, true},
+		{`{"a":"中文"}`, false},
+		{`[`, true},
+	}
+	for _, tt := range tests {
+		err := l.Check([]byte(tt.body))
+		assert.Equal(t, tt.ok, err == nil, tt.body)
+	}
+	assert.NoError(t, JSONLimits{}.Check([]byte(strings.Repeat("[", 1000))))
+
+	// 对象中的逗号不计入数组长度
+	assert.NoError(t, JSONLimits{MaxArrayLen: 2}.Check([]byte(`[{"a":1,"b":2,"c":3},1]`)))
+	// 没有限制数组长度时不随嵌套层数分配内存
+	deep := []byte(strings.Repeat("[", 10000) + strings.Repeat("]", 10000))
+	allocs := testing.AllocsPerRun(10, func() {
+		_ = JSONLimits{MaxStringLen: 5}.Check(deep)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func TestDecodeJSON_Limits(t *testing.T) {
+	var target map[string]interface{}
+	err := decodeJSON(bytes.NewBufferString(strings.Repeat("[", 101)+strings.Repeat("]", 101)), &target)
+	require.Error(t, err)
+	meErr, ok := err.(errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, code.JSONTooComplexErrCode, meErr.Code())
+
+	body := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(`{"a":"0123456789"}`)), 8)
+	err = decodeJSON(body, &target)
+	require.Error(t, err)
+	meErr, ok = err.(errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, code.RequestBodyTooLargeErrCode, meErr.Code())
+}
@@ -0,0 +1,28 @@
+package context
+
+// Principal 认证通过的调用方
+type Principal struct {
+	// ID 调用方唯一标识, 例如用户 id, api key id
+	ID   string
+	Name string
+	// Authenticator 认证方式, 例如 bearer, api_key, basic, session
+	Authenticator string
+	Roles         []string
+	// Permissions 直接授予的权限, 例如 api key 的 scopes
+	Permissions []string
+	// Claims 认证方式提供的额外信息, 例如 token 中的 claims
+	Claims map[string]interface{}
+}
+
+type principalKey struct{}
+
+// Principal implements Contexts.
+func (g *ginContext) Principal() *Principal {
+	p, _ := g.ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// SetPrincipal implements Contexts.
+func (g *ginContext) SetPrincipal(p *Principal) {
+	g.WithValue(principalKey{}, p)
+}
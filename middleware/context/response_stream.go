@@ -0,0 +1,66 @@
+package context
+
+import (
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// ResponseStream 流式返回的文件, 支持 Range 请求和 ETag/Last-Modified 条件请求
+type ResponseStream struct {
+	// FileName 下载时的文件名, 可以包含非 ASCII 字符
+	FileName string
+	// Content 实现 io.Closer 时在请求结束后关闭
+	Content io.ReadSeeker
+	// ModTime 不为零时返回 Last-Modified, 支持 If-Modified-Since
+	ModTime time.Time
+	// ContentType 为空时根据文件名的后缀判断, 无法判断时根据内容判断
+	ContentType string
+	// ETag 为空且 ModTime 不为零时根据 ModTime 和文件大小生成
+	ETag string
+	// Inline 为 true 时浏览器直接打开, 否则作为附件下载
+	Inline bool
+}
+
+func (g *ginContext) SetResponseStream(stream ResponseStream) {
+	g.responseStream.stream = stream
+	g.responseStream.exists = true
+}
+
+func (g *ginContext) SetResponseFilePath(path string, inline bool) errors.Error {
+	name := filepath.Base(path)
+	f, err := os.Open(path)
+	if err != nil {
+		if stdErrors.Is(err, fs.ErrNotExist) {
+			return errors.New(err, code.StreamFileNotFoundErrCode, name)
+		}
+		return errors.New(err, code.StreamFileErrCode, name)
+	}
+	info, err := f.Stat()
+	if err == nil && !info.Mode().IsRegular() {
+		// 目录等不是普通文件, ServeContent 读取时才会失败, 这时响应头已经写出
+		err = fmt.Errorf("%s is not a regular file", path)
+	}
+	if err != nil {
+		_ = f.Close()
+		return errors.New(err, code.StreamFileErrCode, name)
+	}
+	g.SetResponseStream(ResponseStream{
+		FileName: info.Name(),
+		Content:  f,
+		ModTime:  info.ModTime(),
+		Inline:   inline,
+	})
+	return nil
+}
+
+func (g *ginContext) GetResponseStream() (stream ResponseStream, exists bool) {
+	return g.responseStream.stream, g.responseStream.exists
+}
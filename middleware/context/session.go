@@ -0,0 +1,36 @@
+package context
+
+import (
+	osCtx "context"
+)
+
+// Session 请求的 session, 需要启用 session 组件, 见 middleware/session
+type Session interface {
+	// ID session id, 新 session 在第一次保存前也有 id
+	ID() string
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Delete(key string)
+	// Rotate 更换 session id 并保留数据, 登录成功后调用, 防止 session fixation
+	Rotate()
+	// Destroy 删除 session 和 cookie, 用于退出登录
+	Destroy()
+}
+
+type sessionKey struct{}
+
+// SessionFromContext 获取 ctx 中的 session, 没有启用 session 组件时返回 nil
+func SessionFromContext(ctx osCtx.Context) Session {
+	s, _ := ctx.Value(sessionKey{}).(Session)
+	return s
+}
+
+// Session implements Contexts.
+func (g *ginContext) Session() Session {
+	return SessionFromContext(g.ctx)
+}
+
+// SetSession implements Contexts.
+func (g *ginContext) SetSession(s Session) {
+	g.WithValue(sessionKey{}, s)
+}
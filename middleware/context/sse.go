@@ -0,0 +1,181 @@
+package context
+
+import (
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// ErrEventStreamClosed EventStream 已经关闭或者客户端已经断开
+var ErrEventStreamClosed = stdErrors.New("event stream closed")
+
+// Event server-sent event
+type Event struct {
+	// ID 客户端重连时通过 Last-Event-ID 请求头带回
+	ID string
+	// Event 事件类型, 为空时客户端触发 message 事件
+	Event string
+	// Retry 客户端断开后的重连间隔, 为 0 时不发送
+	Retry time.Duration
+	// Data string 和 []byte 原样发送, 其他类型使用 json 序列化。包含换行时拆分为多个 data 行
+	Data interface{}
+}
+
+// EventStream SSE 连接, 可以在多个 goroutine 中使用
+type EventStream struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	done <-chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	stop   chan struct{}
+}
+
+func newEventStream(w http.ResponseWriter, done <-chan struct{}) *EventStream {
+	return &EventStream{
+		w:    w,
+		rc:   http.NewResponseController(w),
+		done: done,
+		stop: make(chan struct{}),
+	}
+}
+
+// Send 发送一个事件, 连接已经关闭时返回 ErrEventStreamClosed
+func (s *EventStream) Send(e Event) error {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return fmt.Errorf("sse id and event must not contain line breaks")
+	}
+	var b strings.Builder
+	if e.ID != "" {
+		b.WriteString("id: " + e.ID + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + e.Event + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	var data string
+	switch v := e.Data.(type) {
+	case nil:
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		data = string(raw)
+	}
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return s.write(b.String())
+}
+
+// Heartbeat 每隔 interval 发送一个注释行, 避免代理因为空闲断开连接, Close 或者客户端断开后停止
+func (s *EventStream) Heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.write(": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-s.stop:
+				return
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止发送事件和心跳, 返回后不会再写 response。handler 返回后 Wrapper 会自动关闭
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+func (s *EventStream) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrEventStreamClosed
+	}
+	select {
+	case <-s.done:
+		return ErrEventStreamClosed
+	default:
+	}
+	if _, err := s.w.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// SSE 写出 text/event-stream 的响应头, 多次调用返回同一个 EventStream
+func (g *ginContext) SSE() (*EventStream, errors.Error) {
+	if g.eventStream != nil {
+		return g.eventStream, nil
+	}
+	w := g.c.Writer
+	if !canFlush(w) {
+		return nil, errors.New(nil, code.StreamingUnsupportedErrCode)
+	}
+	// 与 IsDone 一致, 客户端断开或者超时后结束
+	stream := newEventStream(w, g.ctx.Done())
+	// 长连接不受 server 的 write_timeout 限制
+	_ = stream.rc.SetWriteDeadline(time.Time{})
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	// 关闭 nginx 的缓冲
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	// 响应头可能已经写出, 之后不能再返回 json
+	g.eventStream = stream
+	if err := stream.rc.Flush(); err != nil {
+		return nil, errors.New(err, code.StreamingUnsupportedErrCode)
+	}
+	return stream, nil
+}
+
+func (g *ginContext) GetEventStream() (stream *EventStream, exists bool) {
+	return g.eventStream, g.eventStream != nil
+}
+
+// canFlush 检查最内层的 ResponseWriter 是否支持 Flush, gin 的 ResponseWriter 总是实现了 http.Flusher
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			_, ok = w.(http.Flusher)
+			return ok
+		}
+		w = u.Unwrap()
+	}
+}
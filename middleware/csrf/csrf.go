@@ -0,0 +1,238 @@
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const (
+	DefaultCookieName = "csrf_token"
+	DefaultHeaderName = "X-CSRF-Token"
+	DefaultFormField  = "csrf_token"
+
+	tokenBytes = 32
+)
+
+// safeMethods 不修改数据的请求方法, 只下发 token 不校验
+var safeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Config csrf 配置
+type Config struct {
+	// CookieName 默认 csrf_token, cookie 不是 HttpOnly, 前端需要读取后放到 header 中
+	CookieName string `mapstructure:"cookie_name"`
+	// HeaderName 默认 X-CSRF-Token
+	HeaderName string `mapstructure:"header_name"`
+	// FormField 表单提交时的字段名, 默认 csrf_token
+	FormField string `mapstructure:"form_field"`
+	Path      string `mapstructure:"path"`
+	Domain    string `mapstructure:"domain"`
+	Secure    bool   `mapstructure:"secure"`
+	// SameSite lax(默认), strict, none
+	SameSite string `mapstructure:"same_site"`
+	// SigningKey 不为空时 token 使用 HMAC 签名, 防止通过子域名写入伪造的 cookie
+	SigningKey string `mapstructure:"signing_key"`
+	// TrustedOrigins 允许跨域提交的 origin, 例如 https://admin.example.com。同源请求总是允许
+	TrustedOrigins []string `mapstructure:"trusted_origins"`
+	// CookieRequestsOnly 只校验带 cookie 的请求, 只通过 header 认证的客户端(例如 api key)不需要 token
+	CookieRequestsOnly bool `mapstructure:"cookie_requests_only"`
+}
+
+// Protector double-submit cookie 方式的 csrf 校验
+type Protector struct {
+	conf     Config
+	sameSite http.SameSite
+	trusted  map[string]bool
+}
+
+var (
+	mu               sync.RWMutex
+	defaultProtector *Protector
+)
+
+// SetDefault 启用 csrf 校验, 通过 middleware 注册的路由在登录校验前执行, 路由可以通过 NoCSRF() 跳过。p 为 nil 时关闭
+func SetDefault(p *Protector) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultProtector = p
+}
+
+// Default 获取 SetDefault 设置的 Protector, 没有启用时返回 nil
+func Default() *Protector {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultProtector
+}
+
+func New(conf Config) (*Protector, error) {
+	if conf.CookieName == "" {
+		conf.CookieName = DefaultCookieName
+	}
+	if conf.HeaderName == "" {
+		conf.HeaderName = DefaultHeaderName
+	}
+	if conf.FormField == "" {
+		conf.FormField = DefaultFormField
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+	p := &Protector{conf: conf, trusted: make(map[string]bool, len(conf.TrustedOrigins))}
+	switch strings.ToLower(conf.SameSite) {
+	case "", "lax":
+		p.sameSite = http.SameSiteLaxMode
+	case "strict":
+		p.sameSite = http.SameSiteStrictMode
+	case "none":
+		if !conf.Secure {
+			return nil, fmt.Errorf("csrf same_site none requires secure")
+		}
+		p.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown csrf same_site %q", conf.SameSite)
+	}
+	for _, origin := range conf.TrustedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("invalid csrf trusted origin %q, expect scheme://host[:port]", origin)
+		}
+		p.trusted[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return p, nil
+}
+
+type tokenKey struct{}
+
+// Token 当前请求的 csrf token, 用于渲染表单。没有启用 csrf 时返回空字符串
+func Token(ctx coreContext.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// Check cookie 中没有有效的 token 时下发新的 token。
+// 不安全的请求方法需要 Origin/Referer 同源或者在 TrustedOrigins 中, 并且 header 或者表单中的 token 与 cookie 一致。
+// 读取表单会消费 body, Wrapper 在 limitBody 之后调用
+func (p *Protector) Check(ctx coreContext.Contexts) errors.Error {
+	req := ctx.Request()
+	hasCookies := len(req.Cookies()) > 0
+	token := p.cookieToken(req)
+	cookieValid := token != ""
+	if !cookieValid {
+		token = p.newToken()
+		http.SetCookie(ctx.Response(), &http.Cookie{
+			Name:     p.conf.CookieName,
+			Value:    token,
+			Path:     p.conf.Path,
+			Domain:   p.conf.Domain,
+			Secure:   p.conf.Secure,
+			SameSite: p.sameSite,
+		})
+	}
+	ctx.WithValue(tokenKey{}, token)
+
+	if safeMethods[req.Method] || (p.conf.CookieRequestsOnly && !hasCookies) {
+		return nil
+	}
+	if err := p.checkOrigin(req); err != nil {
+		return p.reject(ctx, err.Error())
+	}
+	if !cookieValid {
+		return p.reject(ctx, "missing csrf cookie")
+	}
+	submitted := req.Header.Get(p.conf.HeaderName)
+	// 只从 urlencoded 表单读取 token, 需要在限制 body 大小之后调用。
+	// multipart 需要解析整个 body (包括文件), 只能通过 header 提交 token
+	if submitted == "" && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		submitted = req.PostFormValue(p.conf.FormField)
+	}
+	if submitted == "" {
+		return p.reject(ctx, "missing csrf token")
+	}
+	if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+		return p.reject(ctx, "csrf token mismatch")
+	}
+	return nil
+}
+
+func (p *Protector) reject(ctx coreContext.Context, reason string) errors.Error {
+	ctx.Log().Infof("csrf: request rejected. reason: %s", reason)
+	return errors.New(nil, code.CSRFCheckFailedErrCode, reason)
+}
+
+// checkOrigin 没有 Origin 时使用 Referer, 都没有时只校验 token。同源判断比较 Origin 的 host 和请求的 Host
+func (p *Protector) checkOrigin(req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		referer := req.Header.Get("Referer")
+		if referer == "" {
+			return nil
+		}
+		u, err := url.Parse(referer)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid referer")
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	if origin == "null" {
+		return fmt.Errorf("origin null is not allowed")
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid origin %s", origin)
+	}
+	if strings.EqualFold(u.Host, req.Host) || p.trusted[strings.ToLower(u.Scheme+"://"+u.Host)] {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin)
+}
+
+// cookieToken 获取 cookie 中的 token, 签名错误时返回空字符串
+func (p *Protector) cookieToken(req *http.Request) string {
+	c, err := req.Cookie(p.conf.CookieName)
+	if err != nil || c.Value == "" {
+		return ""
+	}
+	if p.conf.SigningKey == "" {
+		if len(c.Value) != base64.RawURLEncoding.EncodedLen(tokenBytes) {
+			return ""
+		}
+		return c.Value
+	}
+	random, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.sign(random))) {
+		return ""
+	}
+	return c.Value
+}
+
+func (p *Protector) newToken() string {
+	b := make([]byte, tokenBytes)
+	_, _ = rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if p.conf.SigningKey != "" {
+		token += "." + p.sign(token)
+	}
+	return token
+}
+
+func (p *Protector) sign(value string) string {
+	h := hmac.New(sha256.New, []byte(p.conf.SigningKey))
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package csrf
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rentiansheng/go-api-component/middleware/context/contexttest"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issueToken 通过 GET 请求获取 token cookie
+func issueToken(t *testing.T, p *Protector) *http.Cookie {
+	t.Helper()
+	ctx, w := contexttest.NewContext(httptest.NewRequest(http.MethodGet, "http://api.example.com/form", nil))
+	require.Nil(t, p.Check(ctx))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.False(t, cookies[0].HttpOnly)
+	assert.Equal(t, cookies[0].Value, Token(ctx))
+	return cookies[0]
+}
+
+func TestProtector_Check(t *testing.T) {
+	for name, signingKey := range map[string]string{"plain": "", "signed": "csrf-key"} {
+		t.Run(name, func(t *testing.T) {
+			p, err := New(Config{SigningKey: signingKey, TrustedOrigins: []string{"https://admin.example.com"}})
+			require.NoError(t, err)
+			cookie := issueToken(t, p)
+
+			tests := []struct {
+				name  string
+				setup func(req *http.Request)
+				ok    bool
+			}{
+				{"header", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+				}, true},
+				{"same origin", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+					req.Header.Set("Origin", "https://api.example.com")
+				}, true},
+				{"trusted origin", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+					req.Header.Set("Origin", "https://admin.example.com")
+				}, true},
+				{"cross origin", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+					req.Header.Set("Origin", "https://evil.com")
+				}, false},
+				{"null origin", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+					req.Header.Set("Origin", "null")
+				}, false},
+				{"cross referer", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value)
+					req.Header.Set("Referer", "https://evil.com/page")
+				}, false},
+				{"missing token", func(req *http.Request) { req.AddCookie(cookie) }, false},
+				{"missing cookie", func(req *http.Request) { req.Header.Set("X-CSRF-Token", cookie.Value) }, false},
+				{"mismatch", func(req *http.Request) {
+					req.AddCookie(cookie)
+					req.Header.Set("X-CSRF-Token", cookie.Value+"x")
+				}, false},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					req := httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", nil)
+					tt.setup(req)
+					ctx, _ := contexttest.NewContext(req)
+					err := p.Check(ctx)
+					if tt.ok {
+						assert.Nil(t, err)
+						return
+					}
+					require.NotNil(t, err)
+					assert.Equal(t, code.CSRFCheckFailedErrCode, err.Code())
+				})
+			}
+
+			// 表单字段
+			form := url.Values{"csrf_token": {cookie.Value}, "name": {"n1"}}
+			req := httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookie)
+			ctx, _ := contexttest.NewContext(req)
+			assert.Nil(t, p.Check(ctx))
+			assert.Equal(t, "n1", req.PostFormValue("name"))
+
+			// multipart 不读取表单字段, 需要使用 header
+			body := &bytes.Buffer{}
+			mw := multipart.NewWriter(body)
+			require.NoError(t, mw.WriteField("csrf_token", cookie.Value))
+			require.NoError(t, mw.Close())
+			req = httptest.NewRequest(http.MethodPost, "http://api.example.com/files", bytes.NewReader(body.Bytes()))
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req.AddCookie(cookie)
+			ctx, _ = contexttest.NewContext(req)
+			require.NotNil(t, p.Check(ctx))
+			assert.Nil(t, req.MultipartForm)
+			req = httptest.NewRequest(http.MethodPost, "http://api.example.com/files", bytes.NewReader(body.Bytes()))
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			req.Header.Set("X-CSRF-Token", cookie.Value)
+			req.AddCookie(cookie)
+			ctx, _ = contexttest.NewContext(req)
+			assert.Nil(t, p.Check(ctx))
+		})
+	}
+}
+
+func TestProtector_ForgedCookie(t *testing.T) {
+	p, err := New(Config{SigningKey: "csrf-key"})
+	require.NoError(t, err)
+
+	// 子域名写入的未签名 cookie 无效, 会下发新的 token
+	forged := &http.Cookie{Name: DefaultCookieName, Value: "attacker-token"}
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", nil)
+	req.AddCookie(forged)
+	req.Header.Set("X-CSRF-Token", forged.Value)
+	ctx, w := contexttest.NewContext(req)
+	assert.NotNil(t, p.Check(ctx))
+	require.Len(t, w.Result().Cookies(), 1)
+	assert.NotEqual(t, forged.Value, w.Result().Cookies()[0].Value)
+}
+
+func TestProtector_CookieRequestsOnly(t *testing.T) {
+	p, err := New(Config{CookieRequestsOnly: true})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", nil)
+	req.Header.Set("X-API-Key", "k1")
+	ctx, _ := contexttest.NewContext(req)
+	assert.Nil(t, p.Check(ctx))
+
+	req = httptest.NewRequest(http.MethodPost, "http://api.example.com/orders", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: "s1"})
+	ctx, _ = contexttest.NewContext(req)
+	assert.NotNil(t, p.Check(ctx))
+
+	_, err = New(Config{TrustedOrigins: []string{"admin.example.com"}})
+	assert.Error(t, err)
+}
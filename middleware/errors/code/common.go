@@ -10,4 +10,58 @@ const (
 	FileNotFoundErrCode int32 = 1003
 	// RawErrWrapErrCode raw error wrap: %v
 	RawErrWrapErrCode int32 = 1004
+	// RequestBodyTooLargeErrCode request body too large. limit: %d bytes
+	RequestBodyTooLargeErrCode int32 = 1005
+	// JSONTooComplexErrCode request body too complex. err: %s
+	JSONTooComplexErrCode int32 = 1006
+	// UnsupportedMediaTypeErrCode unsupported content type: %s, supported: %s
+	UnsupportedMediaTypeErrCode int32 = 1007
+	// NotAcceptableErrCode not acceptable: %s, available: %s
+	NotAcceptableErrCode int32 = 1008
+	// InvalidFileErrCode invalid file. field: %s, err: %s
+	InvalidFileErrCode int32 = 1009
+	// StreamingUnsupportedErrCode streaming response is not supported
+	StreamingUnsupportedErrCode int32 = 1010
+	// StreamFileNotFoundErrCode stream file not found. file name: %s
+	StreamFileNotFoundErrCode int32 = 1011
+	// StreamFileErrCode stream file unavailable. file name: %s
+	StreamFileErrCode int32 = 1012
+)
+
+// server
+const (
+	// HealthCheckFailErrCode health check failed. checks: %s
+	HealthCheckFailErrCode int32 = 1100
+	// ServerNotReadyErrCode server is not ready
+	ServerNotReadyErrCode int32 = 1101
+)
+
+// auth
+const (
+	// UnauthenticatedErrCode unauthenticated
+	UnauthenticatedErrCode int32 = 1200
+	// InvalidCredentialsErrCode invalid credentials. err: %s
+	InvalidCredentialsErrCode int32 = 1201
+	// UnknownAuthenticatorErrCode unknown authenticator: %s
+	UnknownAuthenticatorErrCode int32 = 1202
+	// TokenExpiredErrCode token is expired
+	TokenExpiredErrCode int32 = 1203
+	// PermissionDeniedErrCode permission denied. required: %s
+	PermissionDeniedErrCode int32 = 1204
+	// APIKeyQuotaExceededErrCode api key quota exceeded. key: %s
+	APIKeyQuotaExceededErrCode int32 = 1205
+	// SessionStoreErrCode session store error. err: %s
+	SessionStoreErrCode int32 = 1206
+	// CSRFCheckFailedErrCode csrf check failed. err: %s
+	CSRFCheckFailedErrCode int32 = 1207
+)
+
+// traffic
+const (
+	// RateLimitedErrCode too many requests. limiter: %s, retry after: %ds
+	RateLimitedErrCode int32 = 1300
+	// ServerOverloadedErrCode server is overloaded, please retry later
+	ServerOverloadedErrCode int32 = 1301
+	// RequestTimeoutErrCode request timeout. timeout: %s
+	RequestTimeoutErrCode int32 = 1302
 )
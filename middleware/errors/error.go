@@ -77,7 +77,7 @@ func new(ctx context.Context, err error, code int32, args ...interface{}) Error
 
 	message := fmt.Sprintf(format, args...)
 	if err == nil {
-		err = fmt.Errorf(message)
+		err = osErr.New(message)
 	}
 	return &errors{
 		message: message,
@@ -92,7 +92,7 @@ func New(err error, code int32, args ...interface{}) Error {
 	format := register.Get(defaultLang, code)
 	message := fmt.Sprintf(format, args...)
 	if err == nil {
-		err = fmt.Errorf(message)
+		err = osErr.New(message)
 	}
 	return &errors{
 		message: message,
@@ -106,7 +106,7 @@ func New(err error, code int32, args ...interface{}) Error {
 func NewError(code int32, message string) Error {
 	return &errors{
 		message: message,
-		error:   fmt.Errorf(message),
+		error:   osErr.New(message),
 		code:    code,
 		stack:   callers(),
 	}
@@ -116,7 +116,7 @@ func NewErrorOf(code int32, format string, args ...interface{}) Error {
 	message := fmt.Sprintf(format, args...)
 	return &errors{
 		message: message,
-		error:   fmt.Errorf(message),
+		error:   osErr.New(message),
 		code:    code,
 		stack:   callers(),
 	}
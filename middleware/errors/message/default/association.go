@@ -15,5 +15,28 @@ var asstCodes = map[int32]string{
 	code.MapperActionErrCode: "mapper error. action: %s, err: %s",
 	code.FileNotFoundErrCode: "file not found. file name: %s",
 
-	code.RawErrWrapErrCode: "raw error wrap: %v",
+	code.RawErrWrapErrCode:           "raw error wrap: %v",
+	code.RequestBodyTooLargeErrCode:  "request body too large. limit: %d bytes",
+	code.JSONTooComplexErrCode:       "request body too complex. err: %s",
+	code.UnsupportedMediaTypeErrCode: "unsupported content type: %s, supported: %s",
+	code.NotAcceptableErrCode:        "not acceptable: %s, available: %s",
+	code.InvalidFileErrCode:          "invalid file. field: %s, err: %s",
+	code.StreamingUnsupportedErrCode: "streaming response is not supported",
+	code.StreamFileNotFoundErrCode:   "stream file not found. file name: %s",
+	code.StreamFileErrCode:           "stream file unavailable. file name: %s",
+
+	code.HealthCheckFailErrCode: "health check failed. checks: %s",
+	code.ServerNotReadyErrCode:  "server is not ready",
+
+	code.UnauthenticatedErrCode:      "unauthenticated",
+	code.InvalidCredentialsErrCode:   "invalid credentials. err: %s",
+	code.UnknownAuthenticatorErrCode: "unknown authenticator: %s",
+	code.TokenExpiredErrCode:         "token is expired",
+	code.PermissionDeniedErrCode:     "permission denied. required: %s",
+	code.APIKeyQuotaExceededErrCode:  "api key quota exceeded. key: %s",
+	code.SessionStoreErrCode:         "session store error. err: %s",
+	code.CSRFCheckFailedErrCode:      "csrf check failed. err: %s",
+	code.RateLimitedErrCode:          "too many requests. limiter: %s, retry after: %ds",
+	code.ServerOverloadedErrCode:     "server is overloaded, please retry later",
+	code.RequestTimeoutErrCode:       "request timeout. timeout: %s",
 }
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+)
+
+// serveStream 使用 http.ServeContent 返回文件, 由 ServeContent 处理 Range, If-None-Match, If-Modified-Since 等请求头
+func serveStream(g *gin.Context, stream coreContext.ResponseStream) {
+	header := g.Writer.Header()
+	if stream.ContentType != "" {
+		header.Set("Content-Type", stream.ContentType)
+	}
+	if etag := streamETag(stream); etag != "" {
+		header.Set("ETag", etag)
+	}
+	header.Set("Content-Disposition", contentDisposition(stream.Inline, stream.FileName))
+	http.ServeContent(g.Writer, g.Request, stream.FileName, stream.ModTime, stream.Content)
+}
+
+// streamETag 没有指定 ETag 时使用修改时间和文件大小生成, 与 nginx 的格式一致
+func streamETag(stream coreContext.ResponseStream) string {
+	if etag := stream.ETag; etag != "" {
+		if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+			return etag
+		}
+		return `"` + etag + `"`
+	}
+	if stream.ModTime.IsZero() {
+		return ""
+	}
+	size, err := stream.Content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return ""
+	}
+	if _, err := stream.Content.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+	return fmt.Sprintf(`"%x-%x"`, stream.ModTime.Unix(), size)
+}
+
+func closeStream(ctx coreContext.Contexts) {
+	if stream, exists := ctx.GetResponseStream(); exists {
+		if closer, ok := stream.Content.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+	// 停止心跳, 避免 handler 返回后继续写 response
+	if es, exists := ctx.GetEventStream(); exists {
+		es.Close()
+	}
+}
+
+// contentDisposition filename 中只保留 ASCII 字符, 文件名包含其他字符时增加 RFC 5987 编码的 filename*
+func contentDisposition(inline bool, fileName string) string {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+	if fileName == "" {
+		return disposition
+	}
+
+	exact := true
+	fallback := make([]byte, 0, len(fileName))
+	for _, r := range fileName {
+		if r < 0x20 || r >= 0x7f || r == '"' || r == '\\' {
+			fallback = append(fallback, '_')
+			exact = false
+			continue
+		}
+		fallback = append(fallback, byte(r))
+	}
+	value := disposition + `; filename="` + string(fallback) + `"`
+	if !exact {
+		value += "; filename*=UTF-8''" + encodeRFC5987(fileName)
+	}
+	return value
+}
+
+// encodeRFC5987 attr-char 以外的字节使用 %XX 编码
+func encodeRFC5987(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isAttrChar(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isAttrChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
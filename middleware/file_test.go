@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/rentiansheng/go-api-component/middleware/context"
+	. "github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentDisposition(t *testing.T) {
+	assert.Equal(t, `attachment; filename="report.pdf"`, contentDisposition(false, "report.pdf"))
+	assert.Equal(t, `inline; filename="a.png"`, contentDisposition(true, "a.png"))
+	assert.Equal(t, `attachment; filename="__.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv`, contentDisposition(false, "报表.csv"))
+	// 引号和换行不能破坏 header
+	assert.Equal(t, `attachment; filename="a_b_.txt"; filename*=UTF-8''a%22b%0A.txt`, contentDisposition(false, "a\"b\n.txt"))
+	assert.Equal(t, "attachment", contentDisposition(false, ""))
+}
+
+func TestWeb_ResponseStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "报表.csv")
+	require.NoError(t, os.WriteFile(file, []byte("id,name\n1,a\n"), 0600))
+	modTime := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(file, modTime, modTime))
+
+	web := NewWeb("/files")
+	web.Route(web.Get("/report").NoLogin().Handler(func(ctx Contexts) Error {
+		return ctx.SetResponseFilePath(file, false)
+	}))
+	web.Route(web.Get("/missing").NoLogin().Handler(func(ctx Contexts) Error {
+		return ctx.SetResponseFilePath(filepath.Join(dir, "missing.csv"), false)
+	}))
+	web.Route(web.Get("/dir").NoLogin().Handler(func(ctx Contexts) Error {
+		return ctx.SetResponseFilePath(dir, false)
+	}))
+	web.Route(web.Get("/stream").NoLogin().Handler(func(ctx Contexts) Error {
+		ctx.SetResponseStream(ResponseStream{
+			FileName: "hello",
+			Content:  strings.NewReader("hello world"),
+			ETag:     "v1",
+			Inline:   true,
+		})
+		return nil
+	}))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	serve := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/files/report", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id,name\n1,a\n", w.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="__.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8.csv`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t, modTime.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Range
+	w = serve("/files/report", map[string]string{"Range": "bytes=3-6"})
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "name", w.Body.String())
+	assert.Equal(t, "bytes 3-6/12", w.Header().Get("Content-Range"))
+
+	// 条件请求
+	w = serve("/files/report", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = serve("/files/report", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = serve("/files/report", map[string]string{"Range": "bytes=0-1", "If-Range": `"stale"`})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve("/files/missing", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1011`)
+	// 目录在写响应头之前拒绝
+	w = serve("/files/dir", nil)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1012`)
+
+	// 根据内容判断类型
+	w = serve("/files/stream", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `inline; filename="hello"`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	w = serve("/files/stream", map[string]string{"If-None-Match": `"v1"`})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/rentiansheng/go-api-component/pkg/metrics"
+)
+
+// retcodeUnknown panic 或者非 errors.Error 的错误
+const retcodeUnknown = -1
+
+var (
+	httpRequestsTotal = metrics.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests handled by Web routes.", "method", "route", "retcode")
+	httpRequestDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency in seconds.", metrics.DefBuckets, "method", "route", "retcode")
+	httpRequestsInFlight = metrics.NewGaugeVec("http_requests_in_flight",
+		"Number of HTTP requests currently being handled.", "method", "route")
+	apiErrorsTotal = metrics.NewCounterVec("api_errors_total",
+		"Total number of errors.Error returned by handlers, by error code.", "code")
+)
+
+// requestMetrics 记录单个请求的 RED 指标
+type requestMetrics struct {
+	method   string
+	route    string
+	start    time.Time
+	inFlight *metrics.Gauge
+}
+
+func startRequestMetrics(method, route string) *requestMetrics {
+	m := &requestMetrics{
+		method:   method,
+		route:    route,
+		start:    time.Now(),
+		inFlight: httpRequestsInFlight.WithLabelValues(method, route),
+	}
+	m.inFlight.Inc()
+	return m
+}
+
+func (m *requestMetrics) done(retcode int) {
+	m.inFlight.Dec()
+	code := strconv.Itoa(retcode)
+	httpRequestsTotal.WithLabelValues(m.method, m.route, code).Inc()
+	httpRequestDuration.WithLabelValues(m.method, m.route, code).Observe(time.Since(m.start).Seconds())
+}
+
+func observeError(code int32) {
+	apiErrorsTotal.WithLabelValues(strconv.Itoa(int(code))).Inc()
+}
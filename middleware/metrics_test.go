@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/rentiansheng/go-api-component/middleware/context"
+	. "github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapperOptions_Metrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	web := NewWeb("/metrics-test")
+	web.Route(web.Get("/users/:id").NoLogin().Handler(func(ctx Contexts) Error {
+		if ctx.PathParameter("id") == "0" {
+			return NewError(4004, "user not found")
+		}
+		return nil
+	}))
+	web.Route(web.Get("/panic").NoLogin().Handler(func(ctx Contexts) Error {
+		panic("boom")
+	}))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	// 指标注册在全局的 metrics.Default() 中, 比较请求前后的差值
+	route := "/metrics-test/users/:id"
+	ok := httpRequestsTotal.WithLabelValues(http.MethodGet, route, "0").Value()
+	notFound := httpRequestsTotal.WithLabelValues(http.MethodGet, route, "4004").Value()
+	panicked := httpRequestsTotal.WithLabelValues(http.MethodGet, "/metrics-test/panic", "-1").Value()
+	observed := httpRequestDuration.WithLabelValues(http.MethodGet, route, "0").Count()
+	errorsTotal := apiErrorsTotal.WithLabelValues("4004").Value()
+
+	for _, path := range []string{"/metrics-test/users/1", "/metrics-test/users/2", "/metrics-test/users/0"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Panics(t, func() {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics-test/panic", nil))
+	})
+
+	assert.Equal(t, float64(2), httpRequestsTotal.WithLabelValues(http.MethodGet, route, "0").Value()-ok)
+	assert.Equal(t, float64(1), httpRequestsTotal.WithLabelValues(http.MethodGet, route, "4004").Value()-notFound)
+	assert.Equal(t, float64(1), httpRequestsTotal.WithLabelValues(http.MethodGet, "/metrics-test/panic", "-1").Value()-panicked)
+	assert.Equal(t, uint64(2), httpRequestDuration.WithLabelValues(http.MethodGet, route, "0").Count()-observed)
+	assert.Equal(t, float64(0), httpRequestsInFlight.WithLabelValues(http.MethodGet, route).Value())
+	assert.Equal(t, float64(1), apiErrorsTotal.WithLabelValues("4004").Value()-errorsTotal)
+}
+
+func TestGetRetcode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	web := NewWeb("/retcode-test")
+	web.Route(web.Get("/fail").NoLogin().Handler(func(ctx Contexts) Error {
+		return NewError(4004, "user not found")
+	}))
+	web.Route(web.Get("/ok").NoLogin().Handler(func(ctx Contexts) Error {
+		return nil
+	}))
+	engine := gin.New()
+	var retcodes []int
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		retcode, ok := GetRetcode(c)
+		assert.Equal(t, c.FullPath() != "", ok)
+		retcodes = append(retcodes, retcode)
+	})
+	web.RegisterGinRoutes(engine)
+
+	for _, path := range []string{"/retcode-test/fail", "/retcode-test/ok", "/retcode-test/not-found"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, []int{4004, 0, 0}, retcodes)
+}
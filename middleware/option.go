@@ -1,7 +1,24 @@
 package middleware
 
+import (
+	"time"
+
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
+)
+
 type Option struct {
-	noLogin bool
+	noLogin        bool
+	noCSRF         bool
+	stream         bool
+	middlewares    []Middleware
+	authenticators []string
+	permissions    []string
+	roles          []string
+	priority       concurrency.Priority
+	timeout        time.Duration
+	maxBodySize    int64
+	consumes       []ContentType
+	produces       []ContentType
 }
 
 func DefaultOption() Option {
@@ -21,3 +38,113 @@ func (o Option) WithLogin() Option {
 func (o Option) IsNoLogin() bool {
 	return o.noLogin
 }
+
+// WithNoCSRF 跳过 csrf 校验, 见 csrf.SetDefault
+func (o Option) WithNoCSRF() Option {
+	o.noCSRF = true
+	return o
+}
+
+func (o Option) IsNoCSRF() bool {
+	return o.noCSRF
+}
+
+// WithStream 流式响应, 不使用超时时间, 不参与并发限制
+func (o Option) WithStream() Option {
+	o.stream = true
+	return o
+}
+
+func (o Option) IsStream() bool {
+	return o.stream
+}
+
+// WithMiddlewares 追加 handler 中间件, 先添加的在外层
+func (o Option) WithMiddlewares(mws ...Middleware) Option {
+	o.middlewares = append(o.middlewares[:len(o.middlewares):len(o.middlewares)], mws...)
+	return o
+}
+
+func (o Option) Middlewares() []Middleware {
+	return o.middlewares
+}
+
+// WithAuthenticators 指定登录校验使用的认证方式, 见 auth.Register
+func (o Option) WithAuthenticators(names ...string) Option {
+	o.authenticators = names
+	return o
+}
+
+func (o Option) Authenticators() []string {
+	return o.authenticators
+}
+
+// WithPermissions 需要拥有所有权限, 见 auth.Authorize
+func (o Option) WithPermissions(perms ...string) Option {
+	o.permissions = perms
+	return o
+}
+
+func (o Option) Permissions() []string {
+	return o.permissions
+}
+
+// WithRoles 需要拥有其中一个角色, 见 auth.Authorize
+func (o Option) WithRoles(roles ...string) Option {
+	o.roles = roles
+	return o
+}
+
+func (o Option) Roles() []string {
+	return o.roles
+}
+
+// WithPriority 过载时按优先级拒绝请求, 见 SetConcurrencyLimiter
+func (o Option) WithPriority(p concurrency.Priority) Option {
+	o.priority = p
+	return o
+}
+
+func (o Option) Priority() concurrency.Priority {
+	return o.priority
+}
+
+// WithTimeout 请求的超时时间, 为 0 时使用 DefaultTimeout
+func (o Option) WithTimeout(d time.Duration) Option {
+	o.timeout = d
+	return o
+}
+
+func (o Option) Timeout() time.Duration {
+	return o.timeout
+}
+
+// WithMaxBodySize body 大小限制, 为 0 时使用 MaxBodySize, 小于 0 时不限制
+func (o Option) WithMaxBodySize(n int64) Option {
+	o.maxBodySize = n
+	return o
+}
+
+func (o Option) MaxBodySize() int64 {
+	return o.maxBodySize
+}
+
+// WithConsumes 请求 body 允许的 Content-Type, 为空时不限制
+func (o Option) WithConsumes(types ...ContentType) Option {
+	o.consumes = types
+	return o
+}
+
+func (o Option) Consumes() []ContentType {
+	return o.consumes
+}
+
+// WithProduces 响应的 Content-Type, 请求的 Accept 不接受其中任何一个时返回 406, 为空时不限制
+func (o Option) WithProduces(types ...ContentType) Option {
+	o.produces = types
+	return o
+}
+
+func (o Option) Produces() []ContentType {
+	return o.produces
+}
@@ -0,0 +1,166 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rentiansheng/go-api-component/middleware"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const (
+	// TokenBucket 令牌桶, 允许 Burst 大小的突发请求
+	TokenBucket = "token_bucket"
+	// SlidingWindow 滑动窗口, 任意 Period 内的请求数不超过 Rate
+	SlidingWindow = "sliding_window"
+)
+
+// Limit 限流规则, 每个 Period 允许 Rate 个请求
+type Limit struct {
+	// Algorithm token_bucket(默认) 或者 sliding_window
+	Algorithm string        `mapstructure:"algorithm"`
+	Rate      int           `mapstructure:"rate"`
+	Period    time.Duration `mapstructure:"period"`
+	// Burst 令牌桶容量, 默认等于 Rate
+	Burst int `mapstructure:"burst"`
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 && l.Algorithm != SlidingWindow {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return fmt.Errorf("rate limit rate and period must be positive")
+	}
+	if l.Algorithm != "" && l.Algorithm != TokenBucket && l.Algorithm != SlidingWindow {
+		return fmt.Errorf("unknown rate limit algorithm %q", l.Algorithm)
+	}
+	if time.Duration(l.Rate) > l.Period {
+		return fmt.Errorf("rate limit rate must not exceed the number of nanoseconds in period")
+	}
+	return nil
+}
+
+// KeyFunc 获取限流的 key, 返回空字符串时不限流
+type KeyFunc func(ctx coreContext.Context) string
+
+// ByIP 按客户端 ip 限流, 使用连接的地址。经过代理时使用 ByHeader 指定代理设置的 header
+func ByIP(ctx coreContext.Context) string {
+	addr := ctx.Request().RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + addr
+}
+
+// ByPrincipal 按认证通过的调用方限流, 没有认证时按 ip 限流
+func ByPrincipal(ctx coreContext.Context) string {
+	if p := ctx.Principal(); p != nil {
+		return "principal:" + p.Authenticator + ":" + p.ID
+	}
+	return ByIP(ctx)
+}
+
+// ByAPIKey 按请求头中的 api key 限流, header 为空时使用 X-API-Key。key 使用 hash 保存
+func ByAPIKey(header string) KeyFunc {
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return func(ctx coreContext.Context) string {
+		key := strings.TrimSpace(ctx.Header().Get(header))
+		if key == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(key))
+		return "api_key:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+// ByHeader 按请求头限流, 例如代理设置的 X-Real-IP
+func ByHeader(header string) KeyFunc {
+	return func(ctx coreContext.Context) string {
+		value := strings.TrimSpace(ctx.Header().Get(header))
+		if value == "" {
+			return ""
+		}
+		return "header:" + value
+	}
+}
+
+// Limiter 限流器
+type Limiter struct {
+	name  string
+	limit Limit
+	key   KeyFunc
+	store Store
+	now   func() time.Time
+}
+
+// New name 用于区分使用同一个 Store 的限流器, store 为 nil 时使用 MemoryStore
+func New(name string, limit Limit, key KeyFunc, store Store) (*Limiter, error) {
+	if err := limit.validate(); err != nil {
+		return nil, fmt.Errorf("rate limiter %s: %w", name, err)
+	}
+	if limit.Algorithm == "" {
+		limit.Algorithm = TokenBucket
+	}
+	if key == nil {
+		key = ByIP
+	}
+	if store == nil {
+		store = NewMemoryStore(0)
+	}
+	return &Limiter{name: name, limit: limit, key: key, store: store, now: time.Now}, nil
+}
+
+// Allow 消耗一次配额, 设置 RateLimit-* header, 超过限制时设置 Retry-After 并返回 RateLimitedErrCode
+func (l *Limiter) Allow(ctx coreContext.Contexts) errors.Error {
+	key := l.key(ctx)
+	if key == "" {
+		return nil
+	}
+	r := l.store.Take(l.name+"|"+key, l.limit, l.now())
+
+	h := ctx.Response().Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(r.Reset), 10))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", r.Limit, ceilSeconds(l.limit.Period)))
+	if r.Allowed {
+		return nil
+	}
+	retryAfter := ceilSeconds(r.RetryAfter)
+	h.Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	ctx.Log().Infof("ratelimit: request rejected. limiter: %s, key: %s, retry after: %ds", l.name, key, retryAfter)
+	return errors.New(nil, code.RateLimitedErrCode, l.name, retryAfter)
+}
+
+// Middleware 通过 Web.Use 或者 Route.Use 使用, 在登录校验之后执行, 可以按调用方限流
+func (l *Limiter) Middleware() middleware.Middleware {
+	return func(next middleware.Handler) middleware.Handler {
+		return func(ctx coreContext.Contexts) errors.Error {
+			if err := l.Allow(ctx); err != nil {
+				return err
+			}
+			return next(ctx)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	s := NewMemoryStore(4)
+	limit := Limit{Algorithm: TokenBucket, Rate: 10, Period: time.Second, Burst: 3}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		r := s.Take("k", limit, now)
+		assert.True(t, r.Allowed, i)
+		assert.Equal(t, 2-i, r.Remaining)
+		assert.Equal(t, 3, r.Limit)
+	}
+	r := s.Take("k", limit, now)
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 100*time.Millisecond, r.RetryAfter)
+	assert.Equal(t, 300*time.Millisecond, r.Reset)
+
+	// 每 100ms 补充一个 token
+	r = s.Take("k", limit, now.Add(100*time.Millisecond))
+	assert.True(t, r.Allowed)
+	assert.False(t, s.Take("k", limit, now.Add(150*time.Millisecond)).Allowed)
+
+	// 不同的 key 互不影响
+	assert.True(t, s.Take("other", limit, now).Allowed)
+}
+
+func TestMemoryStore_SlidingWindow(t *testing.T) {
+	s := NewMemoryStore(0)
+	limit := Limit{Algorithm: SlidingWindow, Rate: 4, Period: time.Minute}
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		assert.True(t, s.Take("k", limit, now.Add(time.Duration(i)*time.Second)).Allowed, i)
+	}
+	r := s.Take("k", limit, now.Add(30*time.Second))
+	assert.False(t, r.Allowed)
+	assert.Equal(t, 0, r.Remaining)
+	// 下一个窗口中上一个窗口的权重降到 3/4 以下
+	assert.Equal(t, 45*time.Second, r.RetryAfter)
+
+	// 下一个窗口开始 15s, 估算为 4*0.75 = 3, 还能请求一次
+	assert.False(t, s.Take("k", limit, now.Add(74*time.Second)).Allowed)
+	assert.True(t, s.Take("k", limit, now.Add(75*time.Second)).Allowed)
+	assert.False(t, s.Take("k", limit, now.Add(76*time.Second)).Allowed)
+
+	// 间隔超过两个窗口后重置
+	assert.True(t, s.Take("k", limit, now.Add(3*time.Minute)).Allowed)
+}
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	s := NewMemoryStore(8)
+	limit := Limit{Rate: 100, Period: time.Hour}
+	now := time.Now()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if s.Take("k", limit, now).Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, allowed)
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := NewMemoryStore(0)
+	perIP, err := New("ip", Limit{Rate: 2, Period: time.Minute}, ByIP, store)
+	require.NoError(t, err)
+	perKey, err := New("key", Limit{Rate: 1, Period: time.Minute}, ByAPIKey(""), store)
+	require.NoError(t, err)
+
+	handler := func(ctx coreContext.Contexts) errors.Error { return nil }
+	web := middleware.NewWeb("/ratelimit")
+	web.Use(perIP.Middleware())
+	web.Route(web.Get("/a").NoLogin().Handler(handler))
+	web.Route(web.Get("/b").NoLogin().Use(perKey.Middleware()).Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	do := func(path, ip, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/ratelimit/a", "10.0.0.1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	do("/ratelimit/a", "10.0.0.1", "")
+	w = do("/ratelimit/a", "10.0.0.1", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"retcode":1300`)
+
+	// 同一个 web 下的路由共用 ip 限流
+	assert.Equal(t, http.StatusTooManyRequests, do("/ratelimit/b", "10.0.0.1", "k1").Code)
+
+	// 路由自己的 api key 限流, 没有 api key 时不限制
+	assert.Equal(t, http.StatusOK, do("/ratelimit/b", "10.0.0.2", "k1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("/ratelimit/b", "10.0.0.3", "k1").Code)
+	assert.Equal(t, http.StatusOK, do("/ratelimit/b", "10.0.0.4", "").Code)
+	assert.Equal(t, http.StatusOK, do("/ratelimit/b", "10.0.0.4", "k2").Code)
+}
+
+func TestKeyFunc(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.RemoteAddr = "10.0.0.1:1234"
+	c.Request.Header.Set("X-Real-IP", "1.2.3.4")
+	ctx := coreContext.NewContext(c)
+
+	assert.Equal(t, "ip:10.0.0.1", ByIP(ctx))
+	assert.Equal(t, "ip:10.0.0.1", ByPrincipal(ctx))
+	assert.Equal(t, "header:1.2.3.4", ByHeader("X-Real-IP")(ctx))
+	assert.Equal(t, "", ByAPIKey("")(ctx))
+	ctx.SetPrincipal(&coreContext.Principal{ID: "u1", Authenticator: "bearer"})
+	assert.Equal(t, "principal:bearer:u1", ByPrincipal(ctx))
+
+	for _, limit := range []Limit{{}, {Rate: 1}, {Rate: 1, Period: time.Second, Algorithm: "leaky"}} {
+		_, err := New("bad", limit, nil, nil)
+		assert.Error(t, err, fmt.Sprintf("%+v", limit))
+	}
+}
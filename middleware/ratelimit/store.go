@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShards = 64
+	// sweepThreshold shard 中的 key 超过这个数量时清理长时间没有请求的 key
+	sweepThreshold = 4096
+)
+
+// Result 一次限流判断的结果
+type Result struct {
+	Allowed bool
+	// Limit 窗口内允许的请求数
+	Limit int
+	// Remaining 剩余的请求数
+	Remaining int
+	// Reset 配额完全恢复的时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时到下一次可以请求的时间
+	RetryAfter time.Duration
+}
+
+// Store 保存限流状态, 多实例部署时可以实现基于 redis 等的存储
+type Store interface {
+	// Take 消耗 key 的一次配额
+	Take(key string, limit Limit, now time.Time) Result
+}
+
+// MemoryStore 分片的内存存储, 只在单实例内限流
+type MemoryStore struct {
+	shards []*shard
+}
+
+type shard struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	// token bucket
+	tokens float64
+	last   time.Time
+	// sliding window
+	windowStart time.Time
+	prev, curr  int
+	// idle 超过这个时间没有请求时可以清理
+	idle time.Duration
+}
+
+// NewMemoryStore shards 小于等于 0 时使用 64
+func NewMemoryStore(shards int) *MemoryStore {
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	s := &MemoryStore{shards: make([]*shard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &shard{entries: make(map[string]*entry)}
+	}
+	return s
+}
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) Result {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	sh := s.shards[h.Sum32()%uint32(len(s.shards))]
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if len(sh.entries) > sweepThreshold {
+		sh.sweep(now)
+	}
+	e, ok := sh.entries[key]
+	if !ok {
+		e = &entry{tokens: float64(limit.burst()), last: now, windowStart: now, idle: 2 * limit.Period}
+		sh.entries[key] = e
+	}
+	e.idle = 2 * limit.Period
+	if limit.Algorithm == SlidingWindow {
+		return e.slidingWindow(limit, now)
+	}
+	return e.tokenBucket(limit, now)
+}
+
+func (sh *shard) sweep(now time.Time) {
+	for k, e := range sh.entries {
+		if now.Sub(e.last) > e.idle {
+			delete(sh.entries, k)
+		}
+	}
+}
+
+// tokenBucket 容量为 Burst, 每个 Period 补充 Rate 个 token
+func (e *entry) tokenBucket(limit Limit, now time.Time) Result {
+	burst := float64(limit.burst())
+	perToken := limit.Period / time.Duration(limit.Rate)
+	if elapsed := now.Sub(e.last); elapsed > 0 {
+		e.tokens = math.Min(burst, e.tokens+float64(elapsed)/float64(perToken))
+	}
+	e.last = now
+
+	r := Result{Limit: limit.burst()}
+	if e.tokens >= 1 {
+		e.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = time.Duration((1 - e.tokens) * float64(perToken))
+	}
+	r.Remaining = int(e.tokens)
+	r.Reset = time.Duration((burst - e.tokens) * float64(perToken))
+	return r
+}
+
+// slidingWindow 滑动窗口计数, 使用上一个窗口的请求数按时间比例估算
+func (e *entry) slidingWindow(limit Limit, now time.Time) Result {
+	e.last = now
+	if elapsed := now.Sub(e.windowStart); elapsed >= limit.Period {
+		windows := elapsed / limit.Period
+		if windows == 1 {
+			e.prev = e.curr
+		} else {
+			e.prev = 0
+		}
+		e.curr = 0
+		e.windowStart = e.windowStart.Add(windows * limit.Period)
+	}
+	elapsed := now.Sub(e.windowStart)
+	weight := 1 - float64(elapsed)/float64(limit.Period)
+	estimate := float64(e.prev)*weight + float64(e.curr)
+
+	r := Result{Limit: limit.Rate}
+	if estimate+1 <= float64(limit.Rate) {
+		e.curr++
+		r.Allowed = true
+		r.Remaining = int(float64(limit.Rate) - estimate - 1)
+	} else if e.curr+1 <= limit.Rate {
+		// 上一个窗口的权重降低到可以再请求一次的时间
+		r.RetryAfter = time.Duration((1-float64(limit.Rate-e.curr-1)/float64(e.prev))*float64(limit.Period)) - elapsed
+	} else {
+		// 当前窗口已满, 需要等到下一个窗口中当前窗口的权重足够低
+		r.RetryAfter = limit.Period - elapsed + time.Duration((1-float64(limit.Rate-1)/float64(e.curr))*float64(limit.Period))
+	}
+	if r.RetryAfter < 0 {
+		r.RetryAfter = 0
+	}
+	// 窗口中的请求完全移出窗口的时间
+	if e.curr > 0 {
+		r.Reset = 2*limit.Period - elapsed
+	} else if e.prev > 0 {
+		r.Reset = limit.Period - elapsed
+	}
+	return r
+}
@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v3"
+)
+
+// Renderer 将响应序列化为指定的格式
+type Renderer interface {
+	// ContentType 写入响应头的 Content-Type, 例如 application/json; charset=utf-8
+	ContentType() string
+	Render(w io.Writer, v interface{}) error
+}
+
+type rendererFunc struct {
+	contentType string
+	render      func(w io.Writer, v interface{}) error
+}
+
+// NewRenderer 使用函数创建 Renderer
+func NewRenderer(contentType string, render func(w io.Writer, v interface{}) error) Renderer {
+	return rendererFunc{contentType: contentType, render: render}
+}
+
+func (r rendererFunc) ContentType() string {
+	return r.contentType
+}
+
+func (r rendererFunc) Render(w io.Writer, v interface{}) error {
+	return r.render(w, v)
+}
+
+var (
+	renderersMu sync.RWMutex
+	// renderTypes 按注册顺序保存, Accept 中 q 相同时靠前的优先
+	renderTypes []string
+	renderers   = map[string]Renderer{}
+
+	jsonRenderer = NewRenderer("application/json; charset=utf-8", func(w io.Writer, v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+)
+
+func init() {
+	xmlRenderer := NewRenderer("application/xml; charset=utf-8", renderXML)
+	msgpackRenderer := NewRenderer("application/x-msgpack", func(w io.Writer, v interface{}) error {
+		return codec.NewEncoder(w, new(codec.MsgpackHandle)).Encode(v)
+	})
+	RegisterRenderer(ContentTypeJSON, jsonRenderer)
+	RegisterRenderer(ContentTypeXML, xmlRenderer)
+	RegisterRenderer("text/xml", xmlRenderer)
+	RegisterRenderer(ContentTypeYaml, NewRenderer("application/x-yaml; charset=utf-8", func(w io.Writer, v interface{}) error {
+		enc := yaml.NewEncoder(w)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}))
+	RegisterRenderer(ContentTypeToml, NewRenderer("application/toml; charset=utf-8", func(w io.Writer, v interface{}) error {
+		return toml.NewEncoder(w).Encode(v)
+	}))
+	RegisterRenderer(ContentTypeMsgPack, msgpackRenderer)
+	RegisterRenderer("application/msgpack", msgpackRenderer)
+}
+
+// RegisterRenderer 注册 mediaType 的 Renderer, 已经注册时替换。响应根据 Accept 和 Route.Produces 选择 Renderer
+func RegisterRenderer(mediaType ContentType, r Renderer) {
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	if _, ok := renderers[string(mediaType)]; !ok {
+		renderTypes = append(renderTypes, string(mediaType))
+	}
+	renderers[string(mediaType)] = r
+}
+
+// selectRenderer produces 不为空时只使用 produces 中注册了 Renderer 的类型, 第一个为默认类型。
+// Accept 为空或者不接受任何类型时使用默认类型, 没有默认类型时使用 json。
+// produces 为空时保持 json, 只有 Accept 不接受 json 时才使用其他注册的类型, 避免浏览器的 Accept 得到 xml
+func selectRenderer(accept string, produces []ContentType) (r Renderer, negotiated bool) {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+
+	if len(produces) == 0 {
+		if accept == "" || negotiate(accept, []string{string(ContentTypeJSON)}) != "" {
+			return jsonRenderer, len(renderTypes) > 1
+		}
+		if mediaType := negotiate(accept, renderTypes); mediaType != "" {
+			return renderers[mediaType], true
+		}
+		return jsonRenderer, len(renderTypes) > 1
+	}
+	offers := make([]string, 0, len(produces))
+	for _, p := range produces {
+		if _, ok := renderers[string(p)]; ok {
+			offers = append(offers, string(p))
+		}
+	}
+	if len(offers) == 0 {
+		return jsonRenderer, false
+	}
+	if accept != "" {
+		if mediaType := negotiate(accept, offers); mediaType != "" {
+			return renderers[mediaType], len(offers) > 1
+		}
+	}
+	return renderers[offers[0]], len(offers) > 1
+}
+
+// render 按 Accept 序列化响应, 序列化失败时使用 json
+func render(ctx coreContext.Contexts, produces []ContentType, status int, v interface{}) {
+	r, negotiated := selectRenderer(ctx.Request().Header.Get("Accept"), produces)
+	w := ctx.Response()
+	if negotiated {
+		w.Header().Add("Vary", "Accept")
+	}
+	buf := &bytes.Buffer{}
+	if err := r.Render(buf, v); err != nil {
+		ctx.Log().Errorf("render response failed, use json instead. content type: %s, err: %v", r.ContentType(), err)
+		r, buf = jsonRenderer, &bytes.Buffer{}
+		_ = r.Render(buf, v)
+	}
+	w.Header().Set("Content-Type", r.ContentType())
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// renderXML 根元素为 response。encoding/xml 不支持 map, key 为 string 的 map 按 key 排序后作为子元素
+func renderXML(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).EncodeElement(xmlValue{v: v}, xml.StartElement{Name: xml.Name{Local: "response"}})
+}
+
+// MarshalXML 按 retcode, message, data 的顺序输出
+func (r HttpJsonResponse) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name  string
+		value interface{}
+	}{{"retcode", r.Retcode}, {"message", r.Message}, {"data", r.Data}} {
+		if err := e.EncodeElement(xmlValue{v: f.value}, xml.StartElement{Name: xml.Name{Local: f.name}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// xmlValue 支持 map 和元素为 map 的 slice, nil 不输出
+type xmlValue struct {
+	v interface{}
+}
+
+func (x xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	rv := reflect.ValueOf(x.v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch {
+	case !rv.IsValid():
+		return nil
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			if err := e.EncodeElement(xmlValue{v: rv.MapIndex(k).Interface()}, xml.StartElement{Name: xml.Name{Local: k.String()}}); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.EncodeElement(xmlValue{v: rv.Index(i).Interface()}, start); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return e.EncodeElement(rv.Interface(), start)
+	}
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/rentiansheng/go-api-component/middleware/context"
+	. "github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	RegisterRenderer("text/csv", NewRenderer("text/csv; charset=utf-8", func(w io.Writer, v interface{}) error {
+		_, err := w.Write([]byte("retcode\n0\n"))
+		return err
+	}))
+
+	handler := func(ctx Contexts) Error {
+		ctx.SetData(map[string]interface{}{"name": "go"})
+		return nil
+	}
+	web := NewWeb("/render")
+	web.Route(web.Get("/any").NoLogin().Handler(handler))
+	web.Route(web.Get("/xml").NoLogin().Produces(ContentTypeXML, ContentTypeJSON).Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	tests := []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/render/any", "", "application/json; charset=utf-8", `{"retcode":0,"message":"","data":{"name":"go"}}`},
+		{"/render/any", "text/html", "application/json; charset=utf-8", `{"retcode":0,"message":"","data":{"name":"go"}}`},
+		// 没有声明 Produces 时, 浏览器的 Accept 仍然返回 json
+		{"/render/any", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "application/json; charset=utf-8", `{"retcode":0,"message":"","data":{"name":"go"}}`},
+		{"/render/any", "application/xml, application/json;q=0.5", "application/json; charset=utf-8", `{"retcode":0,"message":"","data":{"name":"go"}}`},
+		{"/render/any", "application/xml", "application/xml; charset=utf-8", `<response><retcode>0</retcode><message></message><data><name>go</name></data></response>`},
+		{"/render/any", "application/x-yaml", "application/x-yaml; charset=utf-8", "retcode: 0\nmessage: \"\"\ndata:\n    name: go\n"},
+		{"/render/any", "application/toml", "application/toml; charset=utf-8", "retcode = 0\nmessage = ''\n\n[data]\nname = 'go'\n"},
+		{"/render/any", "application/msgpack", "application/x-msgpack", "\x83\xa7retcode\x00\xa7message\xa0\xa4data\x81\xa4name\xa2go"},
+		{"/render/any", "text/csv", "text/csv; charset=utf-8", "retcode\n0\n"},
+		// 路由默认类型
+		{"/render/xml", "", "application/xml; charset=utf-8", `<response><retcode>0</retcode><message></message><data><name>go</name></data></response>`},
+		{"/render/xml", "application/json", "application/json; charset=utf-8", `{"retcode":0,"message":"","data":{"name":"go"}}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, tt.path+"|"+tt.accept)
+		assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"), tt.path+"|"+tt.accept)
+		assert.Equal(t, tt.body, w.Body.String(), tt.path+"|"+tt.accept)
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	}
+}
+
+func TestRenderXML(t *testing.T) {
+	buf := &bytes.Buffer{}
+	// key 不是 string 的 map 不支持
+	err := renderXML(buf, OkResponse("", struct{ C map[int]string }{C: map[int]string{1: "a"}}))
+	assert.Error(t, err)
+
+	buf.Reset()
+	assert.NoError(t, renderXML(buf, FailResponse(1000, "bad", []map[string]int{{"a": 1}, {"a": 2}})))
+	assert.Equal(t, `<response><retcode>1000</retcode><message>bad</message><data><a>1</a></data><data><a>2</a></data></response>`, buf.String())
+}
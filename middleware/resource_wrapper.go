@@ -2,14 +2,20 @@ package middleware
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware/auth"
 	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/csrf"
 	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/rentiansheng/go-api-component/middleware/session"
 )
 
 var (
@@ -20,17 +26,45 @@ var (
 const (
 	responseHTTHeaderRequestID = "trace-Id"
 	RequestId                  = responseHTTHeaderRequestID
+	// retcodeKey gin.Context 中保存 retcode 的 key
+	retcodeKey = "go-api-component/retcode"
+	// maxRecordBodySize 记录请求 body 的最大长度
+	maxRecordBodySize = 1024 * 1024
 )
 
 type Handler func(ctx coreContext.Contexts) errors.Error
+
+// Middleware handler 中间件, 在 wrapperOptions 中登录校验之后执行, 可以获取完整的 Contexts。
+// 返回错误时不再调用 next, 错误按 errors.Error 返回给调用方
+type Middleware func(next Handler) Handler
 type HttpJsonResponse struct {
-	Retcode int         `json:"retcode"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Retcode int         `json:"retcode" yaml:"retcode" toml:"retcode"`
+	Message string      `json:"message" yaml:"message" toml:"message"`
+	Data    interface{} `json:"data" yaml:"data" toml:"data"`
 }
 
 type CheckLogin func(ctx coreContext.Context) errors.Error
 
+// SetLoginChecker 设置 NeedLogin 路由的登录校验, 优先级高于 auth 中注册的默认认证方式
+func SetLoginChecker(c CheckLogin) {
+	loginChecker = c
+}
+
+// checkLogin 路由指定了认证方式时使用指定的认证方式, 否则使用 SetLoginChecker 设置的校验,
+// 都没有时使用 auth 中注册的默认认证方式。没有任何认证配置时不校验
+func checkLogin(ctx coreContext.Context, authenticators []string) errors.Error {
+	if len(authenticators) > 0 {
+		return auth.Authenticate(ctx, authenticators...)
+	}
+	if loginChecker != nil {
+		return loginChecker(ctx)
+	}
+	if auth.Enabled() {
+		return auth.Authenticate(ctx)
+	}
+	return nil
+}
+
 func OkResponseExtra(message string, data interface{}, extraRespData map[string]interface{}) map[string]interface{} {
 	result := make(map[string]interface{}, 0)
 	for key, val := range extraRespData {
@@ -66,49 +100,167 @@ func Wrapper(h Handler) func(g *gin.Context) {
 	}, DefaultOption())
 }
 
+// Chain 使用中间件包装 handler, mws[0] 在最外层
+func Chain(h Handler, mws ...Middleware) Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
 func wrapperOptions(h Handler, o Option) func(g *gin.Context) {
+	h = Chain(h, o.Middlewares()...)
 	return func(g *gin.Context) {
 		ctx := coreContext.NewContext(g)
 
 		requestID := ctx.GetRequestID()
 		g.Writer.Header().Add(responseHTTHeaderRequestID, requestID)
+		// handler 设置的文件流和 SSE 在请求结束后关闭, 包括返回错误和 panic 的情况
+		defer func() {
+			closeStream(ctx)
+		}()
+
+		// panic 时 retcode 保持 retcodeUnknown
+		retcode := retcodeUnknown
+		reqMetrics := startRequestMetrics(g.Request.Method, ctx.SelectedRoutePath())
+		defer func() {
+			g.Set(retcodeKey, retcode)
+			reqMetrics.done(retcode)
+		}()
 
 		// 从panic中恢复
 		defer func() {
 			if e := recover(); e != nil {
 				ctx.Log().Panicf("panic. err: %#v", e)
+				// 已经开始 SSE 时不能再返回 json
+				if _, exists := ctx.GetEventStream(); exists {
+					return
+				}
 				// gin  返回 json
 				g.JSON(500, e)
 				return
 			}
 		}()
 
-		// 记录请求body
-		requestRecords(ctx)
+		// 请求头中的超时时间不能超过路由的超时时间, 流式响应的路由只在客户端断开时结束
+		timeout := routeTimeout(g, o.Timeout())
+		if o.IsStream() {
+			timeout = 0
+		} else if timeout = requestTimeout(g.Request, timeout); timeout > 0 {
+			ctx.WithTimeout(timeout)
+			cancel := ctx.Cancel()
+			defer cancel()
+		}
+		timedOut := func() bool {
+			return timeout > 0 && ctx.Err() == context.DeadlineExceeded
+		}
+
+		// 过载时尽快拒绝, 不读取 body, 不执行后续的校验。流式响应的长连接会影响自适应限流的延迟统计, 不参与限制
+		var release func(dropped bool)
 		var err errors.Error
+		if !o.IsStream() {
+			release, err = acquireConcurrency(g, ctx.SelectedRoutePath(), o.Priority())
+		}
+		if release != nil {
+			defer func() {
+				release(ctx.Err() == context.DeadlineExceeded)
+			}()
+		}
+
+		if err == nil {
+			err = checkContentType(g.Request, o.Consumes(), o.Produces())
+		}
+		// 在读取 body 之前限制大小
+		if err == nil {
+			err = limitBody(g, o.MaxBodySize())
+		}
+		// 记录请求body
+		if err == nil {
+			requestRecords(ctx)
+		}
 		var data interface{}
 
-		if !o.IsNoLogin() && loginChecker != nil {
-			err = loginChecker(ctx)
+		sessions := session.Default()
+		if sessions != nil && err == nil {
+			sessions.Load(ctx)
+		}
+		if p := csrf.Default(); p != nil && err == nil && !o.IsNoCSRF() {
+			err = p.Check(ctx)
+		}
+		if err == nil && !o.IsNoLogin() {
+			err = checkLogin(ctx, o.Authenticators())
 		}
+		// 登录后校验路由声明的权限
+		if err == nil && (len(o.Permissions()) > 0 || len(o.Roles()) > 0) {
+			err = auth.Authorize(ctx, auth.Requirement{
+				Method:      g.Request.Method,
+				Route:       ctx.SelectedRoutePath(),
+				Permissions: o.Permissions(),
+				Roles:       o.Roles(),
+			})
+		}
+		if err == nil && timedOut() {
+			err = errors.New(nil, code.RequestTimeoutErrCode, timeout.String())
+		}
+		// abandoned 超时后不再等待 handler, handler 仍在其他 goroutine 中运行
+		abandoned := false
 		// 没有前置错误
 		if err == nil {
-			err = h(ctx)
+			if timeout > 0 {
+				// 截止时间到达时立即返回, 不等待忽略 ctx 的 handler
+				var finished bool
+				var panicked interface{}
+				ctx, err, finished, panicked = callWithTimeout(g, ctx, h)
+				abandoned = !finished
+				// ctx 已经替换为 handler 使用的 Contexts, 恢复时可以关闭 handler 设置的文件流和检查 SSE
+				if panicked != nil {
+					panic(panicked)
+				}
+			} else {
+				err = h(ctx)
+			}
 			data = ctx.GetData()
+			// 超时后丢弃 handler 的结果
+			if abandoned || timedOut() {
+				err = errors.New(nil, code.RequestTimeoutErrCode, timeout.String())
+				data = nil
+			}
 			responseRecords(ctx, data, err)
 		}
+		// session 需要在写 response 之前保存, cookie 才能写到 header 中。超时后 handler 可能仍在修改 session, 不保存
+		if sessions != nil && !abandoned {
+			if serr := sessions.Save(ctx); serr != nil && err == nil {
+				err = serr
+			}
+		}
+		// 已经开始 SSE 或者超时前 handler 已经写出响应, 不再返回 json
+		if _, exists := ctx.GetEventStream(); exists || (abandoned && g.Writer.Written()) {
+			retcode = 0
+			if eerr, ok := err.(errors.Error); ok {
+				retcode = int(eerr.Code())
+				observeError(eerr.Code())
+			} else if err != nil {
+				retcode = retcodeUnknown
+			}
+			return
+		}
 		if err != nil {
 			if eerr, ok := err.(errors.Error); ok {
-				g.JSON(200, FailResponse(int(eerr.Code()), eerr.Message(), data))
+				retcode = int(eerr.Code())
+				observeError(eerr.Code())
+				render(ctx, o.Produces(), HTTPStatus(eerr.Code()), FailResponse(retcode, eerr.Message(), data))
 			} else {
-				g.JSON(500, FailResponse(-1, err.Error(), data))
+				render(ctx, o.Produces(), 500, FailResponse(retcodeUnknown, err.Error(), data))
 			}
 		} else {
+			retcode = 0
 
-			if fileName, fileContent, exists := ctx.GetResponseFile(); exists {
+			if stream, exists := ctx.GetResponseStream(); exists {
+				serveStream(g, stream)
+			} else if fileName, fileContent, exists := ctx.GetResponseFile(); exists {
 				// 返回文件下载
 				w := g.Writer
-				w.Header().Add("Content-Disposition", "attachment; filename="+fileName)
+				w.Header().Add("Content-Disposition", contentDisposition(false, fileName))
 				w.Header().Add("Content-Type", "application/octet-stream")
 				w.Header().Add("Content-Length", fmt.Sprintf("%d", fileContent.Len()))
 				_, _ = w.Write(fileContent.Bytes())
@@ -124,15 +276,25 @@ func wrapperOptions(h Handler, o Option) func(g *gin.Context) {
 
 				extraRespData := ctx.GetExtraResponse()
 				if len(extraRespData) > 0 {
-					g.JSON(200, OkResponseExtra("", data, extraRespData))
+					render(ctx, o.Produces(), 200, OkResponseExtra("", data, extraRespData))
 				} else {
-					g.JSON(200, OkResponse("", data))
+					render(ctx, o.Produces(), 200, OkResponse("", data))
 				}
 			}
 		}
 	}
 }
 
+// GetRetcode 获取 Wrapper 处理请求后的 retcode, 用于 access log 等 gin 中间件。请求不是由 Wrapper 处理时返回 false
+func GetRetcode(g *gin.Context) (int, bool) {
+	v, exists := g.Get(retcodeKey)
+	if !exists {
+		return 0, false
+	}
+	retcode, ok := v.(int)
+	return retcode, ok
+}
+
 func responseRecords(ctx coreContext.Contexts, data interface{}, err error) {
 
 	log := ctx.Log()
@@ -163,20 +325,29 @@ func requestRecords(c coreContext.Context) {
 
 	parentReqId := c.Request().Header.Get(RequestId)
 
-	if c.Request().ContentLength < 1024*1024*1 {
+	if c.Request().ContentLength < maxRecordBodySize {
 
 		// Ignore requests smaller than 1MB. This helps prevent delaying
 		ct := c.Request().Header.Get("Content-Type")
 		if strings.ToLower(ct) == "application/json" || strings.HasPrefix(strings.ToLower(ct), "application/json;") {
-			bodyBytes, err := ioutil.ReadAll(c.Request().Body)
+			// Content-Length 未知时最多读取 1MB, 剩余部分留给 handler 读取
+			body := c.Request().Body
+			bodyBytes, err := ioutil.ReadAll(io.LimitReader(body, maxRecordBodySize+1))
+			c.Request().Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(bodyBytes), body), body}
 			if err != nil {
 				log.Errorf("io read request.Body fail, %+v", err)
+			} else if len(bodyBytes) > maxRecordBodySize {
+				log.Infof("middleware: record body. method: %s, uri: %s, parent request id: %s, request body more than 1MB",
+					c.Request().Method, c.Request().RequestURI, parentReqId)
+			} else {
+				// 去除换行符，避免日志换行
+				strBody := strings.Replace(string(bodyBytes), "\n", "", -1)
+				log.Infof("middleware: record body. method: %s, uri: %s, parent request id: %s, body: %s",
+					c.Request().Method, c.Request().RequestURI, parentReqId, strBody)
 			}
-			// 去除换行符，避免日志换行
-			strBody := strings.Replace(string(bodyBytes), "\n", "", -1)
-			log.Infof("middleware: record body. method: %s, uri: %s, parent request id: %s, body: %s",
-				c.Request().Method, c.Request().RequestURI, parentReqId, strBody)
-			c.Request().Body = ioutil.NopCloser(bytes.NewReader(bodyBytes))
 		} else {
 			log.Infof("middleware: record body. method: %s, uri: %s, parent request id: %s, body: not support Content-Type=%v",
 				c.Request().Method, c.Request().RequestURI, parentReqId, ct)
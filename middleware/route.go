@@ -3,8 +3,10 @@ package middleware
 import (
 	"net/http"
 	path2 "path"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
 )
 
 type Web interface {
@@ -18,6 +20,10 @@ type Web interface {
 	Options(string) Route
 
 	Route(r Route)
+	// Use 添加 root 下所有路由的中间件, 在路由自己的中间件之前执行
+	Use(mws ...Middleware)
+	// RouteInfos 已经添加的路由信息, 路径包含 root
+	RouteInfos() []RouteInfo
 
 	RegisterGinRoutes(engine *gin.Engine)
 }
@@ -25,11 +31,66 @@ type Web interface {
 type Route interface {
 	NoLogin() Route
 	NeedLogin() Route
+	// NoCSRF 跳过 csrf 校验, 例如接收第三方回调的路由
+	NoCSRF() Route
+	// Stream 流式响应的路由, 例如 SSE。不使用超时时间, 客户端断开后结束, 不参与并发限制
+	Stream() Route
 	Handler(h Handler) Route
+	// Use 添加当前路由的中间件, 按添加顺序执行
+	Use(mws ...Middleware) Route
+	// Authenticators 指定认证方式, 按顺序尝试, 同时设置为需要登录。见 auth.Register
+	Authenticators(names ...string) Route
+	// Permissions 需要拥有所有权限, 同时设置为需要登录。见 auth.Authorize
+	Permissions(perms ...string) Route
+	// Roles 需要拥有其中一个角色, 同时设置为需要登录。见 auth.Authorize
+	Roles(roles ...string) Route
+	// Priority 过载时优先拒绝低优先级的请求, 默认 concurrency.PriorityNormal
+	Priority(p concurrency.Priority) Route
+	// Timeout 请求的超时时间, 同时是请求头 X-Request-Timeout 的上限。为 0 时使用 DefaultTimeout
+	Timeout(d time.Duration) Route
+	// MaxBodySize body 大小限制, 单位字节。为 0 时使用 middleware.MaxBodySize, 小于 0 时不限制, 例如上传文件的路由
+	MaxBodySize(n int64) Route
+	// Consumes 请求 body 允许的 Content-Type, 支持 type/*, 不匹配时返回 415
+	Consumes(types ...ContentType) Route
+	// Produces 响应的 Content-Type, 请求的 Accept 不接受其中任何一个时返回 406
+	Produces(types ...ContentType) Route
 	GetPath() string
 	GetMethod() string
 	GetHandler() Handler
+	GetMiddlewares() []Middleware
+	GetAuthenticators() []string
+	GetPermissions() []string
+	GetRoles() []string
+	GetPriority() concurrency.Priority
+	GetTimeout() time.Duration
+	GetMaxBodySize() int64
+	GetConsumes() []ContentType
+	GetProduces() []ContentType
 	IsLoginRequired() bool
+	IsCSRFExempt() bool
+	IsStream() bool
+}
+
+// RouteInfo 路由信息, 用于路由列表展示
+type RouteInfo struct {
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	LoginRequired bool   `json:"login_required"`
+	// Authenticators 路由指定的认证方式, 为空时使用默认认证方式
+	Authenticators []string `json:"authenticators,omitempty"`
+	Permissions    []string `json:"permissions,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	CSRFExempt     bool     `json:"csrf_exempt,omitempty"`
+	Stream         bool     `json:"stream,omitempty"`
+	// Priority 不是默认优先级时的优先级名称
+	Priority string `json:"priority,omitempty"`
+	// Timeout 路由指定的超时时间, 例如 5s
+	Timeout string `json:"timeout,omitempty"`
+	// MaxBodySize 路由指定的 body 大小限制
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+	// Consumes, Produces 路由声明的请求和响应类型
+	Consumes []string `json:"consumes,omitempty"`
+	Produces []string `json:"produces,omitempty"`
 }
 
 type ContentType string
@@ -43,8 +104,21 @@ const (
 	ContentTypeMsgPack     ContentType = "application/x-msgpack"
 	ContentTypeYaml        ContentType = "application/x-yaml"
 	ContentTypeToml        ContentType = "application/toml"
+	ContentTypeForm        ContentType = "application/x-www-form-urlencoded"
+	ContentTypeMultipart   ContentType = "multipart/form-data"
 )
 
+func contentTypeStrings(types []ContentType) []string {
+	if len(types) == 0 {
+		return nil
+	}
+	s := make([]string, 0, len(types))
+	for _, t := range types {
+		s = append(s, string(t))
+	}
+	return s
+}
+
 func NewWeb(root string) Web {
 	return &web{
 		routers: make([]Route, 0),
@@ -53,8 +127,9 @@ func NewWeb(root string) Web {
 }
 
 type web struct {
-	routers []Route
-	root    string
+	routers     []Route
+	root        string
+	middlewares []Middleware
 }
 
 func (w web) Get(path string) Route {
@@ -101,12 +176,64 @@ func (w *web) Route(r Route) {
 	w.routers = append(w.routers, r)
 }
 
+func (w *web) Use(mws ...Middleware) {
+	w.middlewares = append(w.middlewares, mws...)
+}
+
+func (w *web) RouteInfos() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(w.routers))
+	for _, r := range w.routers {
+		var priority string
+		if r.GetPriority() != concurrency.PriorityNormal {
+			priority = r.GetPriority().String()
+		}
+		var timeout string
+		if r.GetTimeout() > 0 {
+			timeout = r.GetTimeout().String()
+		}
+		infos = append(infos, RouteInfo{
+			Method:         r.GetMethod(),
+			Path:           path2.Join(w.root, r.GetPath()),
+			LoginRequired:  r.IsLoginRequired(),
+			Authenticators: r.GetAuthenticators(),
+			Permissions:    r.GetPermissions(),
+			Roles:          r.GetRoles(),
+			CSRFExempt:     r.IsCSRFExempt(),
+			Stream:         r.IsStream(),
+			Priority:       priority,
+			Timeout:        timeout,
+			MaxBodySize:    r.GetMaxBodySize(),
+			Consumes:       contentTypeStrings(r.GetConsumes()),
+			Produces:       contentTypeStrings(r.GetProduces()),
+		})
+	}
+	return infos
+}
+
 func (w *web) RegisterGinRoutes(engine *gin.Engine) {
 	for _, r := range w.routers {
 		o := DefaultOption()
 		if !r.IsLoginRequired() {
-			o.WithNoLogin()
+			o = o.WithNoLogin()
+		}
+		if r.IsCSRFExempt() {
+			o = o.WithNoCSRF()
 		}
+		if r.IsStream() {
+			o = o.WithStream()
+		}
+		o = o.WithAuthenticators(r.GetAuthenticators()...).
+			WithPermissions(r.GetPermissions()...).
+			WithRoles(r.GetRoles()...).
+			WithPriority(r.GetPriority()).
+			WithTimeout(r.GetTimeout()).
+			WithMaxBodySize(r.GetMaxBodySize()).
+			WithConsumes(r.GetConsumes()...).
+			WithProduces(r.GetProduces()...)
+		// web 的中间件在外层
+		mws := make([]Middleware, 0, len(w.middlewares)+len(r.GetMiddlewares()))
+		mws = append(mws, w.middlewares...)
+		o = o.WithMiddlewares(append(mws, r.GetMiddlewares()...)...)
 
 		fullPath := path2.Join(w.root, r.GetPath())
 		handler := wrapperOptions(r.GetHandler(), o)
@@ -131,11 +258,21 @@ func (w *web) RegisterGinRoutes(engine *gin.Engine) {
 }
 
 type route struct {
-	noLogin     bool
-	handler     Handler
-	method      string
-	path        string
-	contentType ContentType
+	noLogin        bool
+	noCSRF         bool
+	stream         bool
+	handler        Handler
+	middlewares    []Middleware
+	authenticators []string
+	permissions    []string
+	roles          []string
+	priority       concurrency.Priority
+	timeout        time.Duration
+	maxBodySize    int64
+	method         string
+	path           string
+	consumes       []ContentType
+	produces       []ContentType
 }
 
 func (r route) Get(path string) Route {
@@ -188,11 +325,69 @@ func (r *route) NeedLogin() Route {
 	return r
 }
 
+func (r *route) NoCSRF() Route {
+	r.noCSRF = true
+	return r
+}
+
+func (r *route) Stream() Route {
+	r.stream = true
+	return r
+}
+
 func (r *route) Handler(h Handler) Route {
 	r.handler = h
 	return r
 }
 
+func (r *route) Use(mws ...Middleware) Route {
+	r.middlewares = append(r.middlewares, mws...)
+	return r
+}
+
+func (r *route) Authenticators(names ...string) Route {
+	r.noLogin = false
+	r.authenticators = names
+	return r
+}
+
+func (r *route) Permissions(perms ...string) Route {
+	r.noLogin = false
+	r.permissions = perms
+	return r
+}
+
+func (r *route) Roles(roles ...string) Route {
+	r.noLogin = false
+	r.roles = roles
+	return r
+}
+
+func (r *route) Priority(p concurrency.Priority) Route {
+	r.priority = p
+	return r
+}
+
+func (r *route) Timeout(d time.Duration) Route {
+	r.timeout = d
+	return r
+}
+
+func (r *route) MaxBodySize(n int64) Route {
+	r.maxBodySize = n
+	return r
+}
+
+func (r *route) Consumes(types ...ContentType) Route {
+	r.consumes = types
+	return r
+}
+
+func (r *route) Produces(types ...ContentType) Route {
+	r.produces = types
+	return r
+}
+
 func (r *route) GetPath() string {
 	return r.path
 }
@@ -205,6 +400,50 @@ func (r *route) GetHandler() Handler {
 	return r.handler
 }
 
+func (r *route) GetMiddlewares() []Middleware {
+	return r.middlewares
+}
+
+func (r *route) GetAuthenticators() []string {
+	return r.authenticators
+}
+
+func (r *route) GetPermissions() []string {
+	return r.permissions
+}
+
+func (r *route) GetRoles() []string {
+	return r.roles
+}
+
+func (r *route) GetPriority() concurrency.Priority {
+	return r.priority
+}
+
+func (r *route) GetTimeout() time.Duration {
+	return r.timeout
+}
+
+func (r *route) GetMaxBodySize() int64 {
+	return r.maxBodySize
+}
+
+func (r *route) GetConsumes() []ContentType {
+	return r.consumes
+}
+
+func (r *route) GetProduces() []ContentType {
+	return r.produces
+}
+
 func (r *route) IsLoginRequired() bool {
 	return !r.noLogin
 }
+
+func (r *route) IsCSRFExempt() bool {
+	return r.noCSRF
+}
+
+func (r *route) IsStream() bool {
+	return r.stream
+}
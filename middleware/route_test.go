@@ -1,13 +1,21 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware/auth"
 	. "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/csrf"
 	. "github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/session"
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -284,3 +292,643 @@ func TestContentTypeConstants(t *testing.T) {
 	assert.Equal(t, ContentType("application/x-yaml"), ContentTypeYaml)
 	assert.Equal(t, ContentType("application/toml"), ContentTypeToml)
 }
+
+func TestWeb_RouteInfos(t *testing.T) {
+	web := NewWeb("/api/v1")
+	web.Route(web.Get("/users/:id").NoLogin())
+	web.Route(web.Post("/users"))
+
+	assert.Equal(t, []RouteInfo{
+		{Method: http.MethodGet, Path: "/api/v1/users/:id", LoginRequired: false},
+		{Method: http.MethodPost, Path: "/api/v1/users", LoginRequired: true},
+	}, web.RouteInfos())
+}
+
+func TestWeb_Use(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx Contexts) Error {
+				calls = append(calls, name+":"+ctx.SelectedRoutePath())
+				if err := next(ctx); err != nil {
+					return err
+				}
+				calls = append(calls, name+":after")
+				return nil
+			}
+		}
+	}
+	deny := func(next Handler) Handler {
+		return func(ctx Contexts) Error {
+			if ctx.GetRequestID() == "" {
+				return NewError(500, "missing trace id")
+			}
+			return NewError(4003, "denied")
+		}
+	}
+
+	web := NewWeb("/use")
+	web.Route(web.Get("/users").NoLogin().Use(trace("route")).Handler(func(ctx Contexts) Error {
+		calls = append(calls, "handler")
+		ctx.SetData("ok")
+		return nil
+	}))
+	web.Route(web.Get("/denied").NoLogin().Use(deny).Handler(func(ctx Contexts) Error {
+		calls = append(calls, "denied handler")
+		return nil
+	}))
+	// 在 Route 之后调用 Use 也对已添加的路由生效
+	web.Use(trace("web1"), trace("web2"))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/use/users", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":"ok"`)
+	assert.Equal(t, []string{"web1:/use/users", "web2:/use/users", "route:/use/users", "handler",
+		"route:after", "web2:after", "web1:after"}, calls)
+
+	calls = nil
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/use/denied", nil))
+	assert.Contains(t, w.Body.String(), `"retcode":4003`)
+	assert.Equal(t, []string{"web1:/use/denied", "web2:/use/denied"}, calls)
+}
+
+func TestWeb_Authenticators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth.Reset()
+	defer auth.Reset()
+	auth.Register(auth.NewBearer(func(ctx Context, token string) (*Principal, error) {
+		if token != "t1" {
+			return nil, nil
+		}
+		return &Principal{ID: "u1"}, nil
+	}))
+	auth.Register(auth.NewAPIKey("", func(ctx Context, key string) (*Principal, error) {
+		return &Principal{ID: "key-" + key}, nil
+	}))
+
+	handler := func(ctx Contexts) Error {
+		if p := ctx.Principal(); p != nil {
+			ctx.SetData(p.ID)
+		}
+		return nil
+	}
+	web := NewWeb("/auth")
+	web.Route(web.Get("/public").NoLogin().Handler(handler))
+	web.Route(web.Get("/me").Handler(handler))
+	web.Route(web.Get("/key").Authenticators(auth.NameAPIKey).Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	do := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/auth/public", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":0`)
+
+	w = do("/auth/me", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1200`)
+	assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+
+	w = do("/auth/me", map[string]string{"Authorization": "Bearer t1"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"data":"u1"`)
+
+	w = do("/auth/me", map[string]string{"X-API-Key": "k1"})
+	assert.Contains(t, w.Body.String(), `"data":"key-k1"`)
+
+	// 路由指定只使用 api key
+	w = do("/auth/key", map[string]string{"Authorization": "Bearer t1"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do("/auth/key", map[string]string{"X-API-Key": "k1"})
+	assert.Contains(t, w.Body.String(), `"data":"key-k1"`)
+
+	// SetLoginChecker 优先于默认认证方式, 路由指定的认证方式不受影响
+	SetLoginChecker(func(ctx Context) Error {
+		ctx.SetPrincipal(&Principal{ID: "checker"})
+		return nil
+	})
+	defer SetLoginChecker(nil)
+	w = do("/auth/me", nil)
+	assert.Contains(t, w.Body.String(), `"data":"checker"`)
+	w = do("/auth/key", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	infos := web.RouteInfos()
+	assert.Equal(t, []string{auth.NameAPIKey}, infos[2].Authenticators)
+	assert.True(t, infos[2].LoginRequired)
+}
+
+func TestWeb_Permissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth.Reset()
+	defer auth.Reset()
+	auth.Register(auth.NewBearer(func(ctx Context, token string) (*Principal, error) {
+		return &Principal{ID: token, Roles: []string{token}}, nil
+	}))
+	auth.SetAuthorizer(auth.NewRBAC(map[string][]string{"admin": {"*"}, "viewer": {"order:read"}}))
+	defer auth.SetAuthorizer(auth.NewRBAC(nil))
+
+	called := 0
+	handler := func(ctx Contexts) Error {
+		called++
+		return nil
+	}
+	web := NewWeb("/rbac")
+	web.Route(web.Post("/orders").NoLogin().Permissions("order:write").Handler(handler))
+	web.Route(web.Delete("/orders").Roles("admin").Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	do := func(method, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/rbac/orders", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// Permissions 会覆盖之前的 NoLogin
+	w := do(http.MethodPost, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do(http.MethodPost, "viewer")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1204`)
+	w = do(http.MethodDelete, "viewer")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 0, called)
+
+	w = do(http.MethodPost, "admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodDelete, "admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, called)
+
+	infos := web.RouteInfos()
+	assert.True(t, infos[0].LoginRequired)
+	assert.Equal(t, []string{"order:write"}, infos[0].Permissions)
+	assert.Equal(t, []string{"admin"}, infos[1].Roles)
+}
+
+func TestWeb_Session(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth.Reset()
+	defer auth.Reset()
+	m, err := session.NewManager(session.Config{SigningKey: "0123456789abcdef0123456789abcdef"}, session.NewMemoryStore())
+	assert.NoError(t, err)
+	session.SetDefault(m)
+	defer session.SetDefault(nil)
+	auth.Register(session.NewAuthenticator())
+
+	web := NewWeb("/session")
+	web.Route(web.Post("/login").NoLogin().Handler(func(ctx Contexts) Error {
+		return session.Login(ctx, &Principal{ID: "u1"})
+	}))
+	web.Route(web.Get("/me").Handler(func(ctx Contexts) Error {
+		ctx.SetData(ctx.Principal().ID)
+		return nil
+	}))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/session/me", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/session/login", nil))
+	cookies := w.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/session/me", nil)
+		req.AddCookie(cookies[0])
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data":"u1"`)
+	}
+}
+
+func TestWeb_CSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p, err := csrf.New(csrf.Config{})
+	assert.NoError(t, err)
+	csrf.SetDefault(p)
+	defer csrf.SetDefault(nil)
+
+	handler := func(ctx Contexts) Error { return nil }
+	web := NewWeb("/csrf")
+	web.Route(web.Post("/orders").NoLogin().Handler(handler))
+	web.Route(web.Post("/webhook").NoLogin().NoCSRF().Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/csrf/orders", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1207`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/csrf/webhook", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, web.RouteInfos()[1].CSRFExempt)
+}
+
+func TestWeb_Concurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	l := concurrency.NewWithAlgorithm(concurrency.NewAIMD(1, 1, 1, time.Second, 0.5))
+	SetConcurrencyLimiter(l)
+	defer SetConcurrencyLimiter(nil)
+
+	// 占用一个许可, normal 只能使用 90%
+	release, ok := l.Acquire(concurrency.PriorityHigh)
+	assert.True(t, ok)
+	defer release(false)
+
+	handler := func(ctx Contexts) Error { return nil }
+	web := NewWeb("/overload")
+	web.Route(web.Get("/normal").NoLogin().Handler(handler))
+	web.Route(web.Get("/health").NoLogin().Priority(concurrency.PriorityCritical).Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/overload/normal", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1301`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/overload/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, l.InFlight())
+	assert.Equal(t, "critical", web.RouteInfos()[1].Priority)
+
+	// engine 的并发限制优先, 不受全局设置影响
+	other := concurrency.NewWithAlgorithm(concurrency.NewAIMD(10, 10, 10, time.Second, 0.5))
+	scoped := gin.New()
+	scoped.Use(ConcurrencyLimiterHandler(other))
+	web.RegisterGinRoutes(scoped)
+	w = httptest.NewRecorder()
+	scoped.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/overload/normal", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestWeb_Timeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// handler 在单独的 goroutine 中执行
+	var deadline atomic.Int64
+	slow := func(ctx Contexts) Error {
+		if d, ok := ctx.Deadline(); ok {
+			deadline.Store(int64(time.Until(d)))
+		}
+		<-ctx.Done()
+		return nil
+	}
+	fast := func(ctx Contexts) Error {
+		ctx.SetData("ok")
+		return nil
+	}
+	web := NewWeb("/timeout")
+	web.Route(web.Get("/slow").NoLogin().Timeout(20 * time.Millisecond).Handler(slow))
+	web.Route(web.Get("/fast").NoLogin().Timeout(time.Second).Handler(fast))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1302`)
+	assert.InDelta(t, 20*time.Millisecond, time.Duration(deadline.Load()), float64(10*time.Millisecond))
+
+	// 请求头不能超过路由的超时时间
+	req := httptest.NewRequest(http.MethodGet, "/timeout/slow", nil)
+	req.Header.Set(RequestTimeoutHeader, "10s")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.InDelta(t, 20*time.Millisecond, time.Duration(deadline.Load()), float64(10*time.Millisecond))
+
+	req = httptest.NewRequest(http.MethodGet, "/timeout/slow", nil)
+	req.Header.Set(RequestTimeoutHeader, "5")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "timeout: 5ms")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/fast", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1s", web.RouteInfos()[1].Timeout)
+}
+
+func TestWeb_TimeoutIgnoredContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	finished := make(chan struct{})
+	web := NewWeb("/timeout")
+	// handler 不检查 ctx, 超时后仍然继续运行
+	web.Route(web.Get("/blocking").NoLogin().Timeout(20 * time.Millisecond).Handler(func(ctx Contexts) Error {
+		defer close(finished)
+		time.Sleep(100 * time.Millisecond)
+		ctx.Response().Header().Set("X-Late", "1")
+		_, _ = ctx.Response().Write([]byte("late"))
+		ctx.SetData("late")
+		return nil
+	}))
+	web.Route(web.Get("/header").NoLogin().Timeout(time.Second).Handler(func(ctx Contexts) Error {
+		ctx.Response().Header().Set("X-Handler", "1")
+		ctx.SetData(ctx.PathParameters())
+		return nil
+	}))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/blocking", nil))
+	assert.Less(t, time.Since(start), 80*time.Millisecond)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1302`)
+
+	// handler 结束后的写入被丢弃
+	<-finished
+	assert.NotContains(t, w.Body.String(), "late")
+	assert.Empty(t, w.Header().Get("X-Late"))
+
+	// 按时返回时保留 handler 设置的 header
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/header", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Handler"))
+	assert.NotEmpty(t, w.Header().Get("Trace-Id"))
+}
+
+type closeRecorder struct {
+	*strings.Reader
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+func TestWeb_TimeoutPanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	content := &closeRecorder{Reader: strings.NewReader("hello")}
+	web := NewWeb("/timeout")
+	web.Route(web.Get("/stream").NoLogin().Timeout(time.Second).Handler(func(ctx Contexts) Error {
+		ctx.SetResponseStream(ResponseStream{FileName: "a.txt", Content: content})
+		panic("boom")
+	}))
+	web.Route(web.Get("/sse").NoLogin().Timeout(time.Second).Handler(func(ctx Contexts) Error {
+		if _, err := ctx.SSE(); err != nil {
+			return err
+		}
+		panic("boom")
+	}))
+	web.Route(web.Get("/keys").NoLogin().Timeout(time.Second).Handler(func(ctx Contexts) Error {
+		ctx.(interface{ SetValue(string, interface{}) }).SetValue("handler-key", "v1")
+		return nil
+	}))
+	engine := gin.New()
+	var key interface{}
+	engine.Use(func(c *gin.Context) {
+		c.Next()
+		key, _ = c.Get("handler-key")
+	})
+	web.RegisterGinRoutes(engine)
+
+	// panic 时关闭 handler 设置的文件流
+	w := httptest.NewRecorder()
+	assert.Panics(t, func() {
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/stream", nil))
+	})
+	assert.True(t, content.closed.Load())
+
+	// 已经开始 SSE 时不再返回 json
+	w = httptest.NewRecorder()
+	assert.Panics(t, func() {
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/sse", nil))
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Body.String(), "boom")
+
+	// handler 在 gin.Context 副本上设置的 Keys
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/timeout/keys", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", key)
+}
+
+func TestRequestTimeoutHeader(t *testing.T) {
+	for v, expected := range map[string]time.Duration{"1500ms": 1500 * time.Millisecond, "2s": 2 * time.Second, "250": 250 * time.Millisecond} {
+		d, ok := ParseRequestTimeout(v)
+		assert.True(t, ok, v)
+		assert.Equal(t, expected, d, v)
+	}
+	for _, v := range []string{"", "abc", "-1s", "0"} {
+		_, ok := ParseRequestTimeout(v)
+		assert.False(t, ok, v)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	h := http.Header{}
+	SetRequestTimeoutHeader(ctx, h)
+	d, ok := ParseRequestTimeout(h.Get(RequestTimeoutHeader))
+	assert.True(t, ok)
+	assert.InDelta(t, time.Second, d, float64(50*time.Millisecond))
+
+	h = http.Header{}
+	SetRequestTimeoutHeader(context.Background(), h)
+	assert.Empty(t, h.Get(RequestTimeoutHeader))
+}
+
+func TestWeb_MaxBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetMaxBodySize(16)
+	defer SetMaxBodySize(0)
+
+	handler := func(ctx Contexts) Error {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := ctx.Decode(&body); err != nil {
+			return err
+		}
+		ctx.SetData(body)
+		return nil
+	}
+	web := NewWeb("/body")
+	web.Route(web.Post("/small").NoLogin().Handler(handler))
+	web.Route(web.Post("/upload").NoLogin().MaxBodySize(-1).Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	large := `{"name":"0123456789abcdef"}`
+	newReq := func(path string, chunked bool) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(large))
+		req.Header.Set("Content-Type", "application/json")
+		if chunked {
+			req.ContentLength = -1
+		}
+		return req
+	}
+
+	// Content-Length 超过限制
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, newReq("/body/small", false))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1005`)
+
+	// 读取时超过限制
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, newReq("/body/small", true))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":1005`)
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, newReq("/body/upload", true))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// engine 的设置优先于全局设置
+	scoped := gin.New()
+	scoped.Use(MaxBodySizeHandler(1024))
+	web.RegisterGinRoutes(scoped)
+	w = httptest.NewRecorder()
+	scoped.ServeHTTP(w, newReq("/body/small", false))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "0123456789abcdef")
+	assert.Equal(t, int64(-1), web.RouteInfos()[1].MaxBodySize)
+}
+
+func TestWeb_ContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := func(ctx Contexts) Error { return nil }
+	web := NewWeb("/ct")
+	web.Route(web.Post("/orders").NoLogin().
+		Consumes(ContentTypeJSON, ContentTypeForm).
+		Produces(ContentTypeJSON).
+		Handler(handler))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	tests := []struct {
+		contentType string
+		accept      string
+		body        string
+		status      int
+		retcode     string
+	}{
+		{"application/json; charset=utf-8", "application/json", "{}", http.StatusOK, `"retcode":0`},
+		{"application/x-www-form-urlencoded", "*/*", "a=1", http.StatusOK, `"retcode":0`},
+		{"", "", "", http.StatusOK, `"retcode":0`},
+		{"text/plain", "", "hello", http.StatusUnsupportedMediaType, `"retcode":1007`},
+		{"", "", "hello", http.StatusUnsupportedMediaType, `"retcode":1007`},
+		{"application/json", "application/xml", "{}", http.StatusNotAcceptable, `"retcode":1008`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/ct/orders", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, tt.status, w.Code, tt.contentType+"|"+tt.accept)
+		assert.Contains(t, w.Body.String(), tt.retcode)
+	}
+
+	info := web.RouteInfos()[0]
+	assert.Equal(t, []string{"application/json", "application/x-www-form-urlencoded"}, info.Consumes)
+	assert.Equal(t, []string{"application/json"}, info.Produces)
+}
+
+func TestWeb_Stream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetDefaultTimeout(10 * time.Millisecond)
+	defer SetDefaultTimeout(0)
+
+	web := NewWeb("/sse")
+	web.Route(web.Get("/progress").NoLogin().Stream().Handler(func(ctx Contexts) Error {
+		es, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		es.Heartbeat(2 * time.Millisecond)
+		assert.NoError(t, es.Send(Event{ID: "1", Event: "progress", Retry: time.Second, Data: map[string]int{"done": 50}}))
+		// 超过默认超时时间也不会结束
+		time.Sleep(50 * time.Millisecond)
+		assert.False(t, ctx.IsDone())
+		assert.NoError(t, es.Send(Event{Data: "line1\nline2"}))
+		return nil
+	}))
+	web.Route(web.Get("/disconnect").NoLogin().Stream().Handler(func(ctx Contexts) Error {
+		es, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		for !ctx.IsDone() {
+			time.Sleep(time.Millisecond)
+		}
+		assert.ErrorIs(t, es.Send(Event{Data: "lost"}), ErrEventStreamClosed)
+		return nil
+	}))
+	web.Route(web.Get("/fail").NoLogin().Stream().Handler(func(ctx Contexts) Error {
+		es, err := ctx.SSE()
+		if err != nil {
+			return err
+		}
+		_ = es.Send(Event{Event: "error", Data: "export failed"})
+		return New(nil, 1003, "report.csv")
+	}))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sse/progress", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "id: 1\nevent: progress\nretry: 1000\ndata: {\"done\":50}\n\n"), body)
+	assert.Contains(t, body, ": heartbeat\n\n")
+	assert.True(t, strings.HasSuffix(body, "data: line1\ndata: line2\n\n"), body)
+	assert.NotContains(t, body, "retcode")
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		cancel()
+	}()
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sse/disconnect", nil).WithContext(reqCtx))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// 开始 SSE 之后的错误不再返回 json
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/sse/fail", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "event: error\ndata: export failed\n\n", w.Body.String())
+
+	assert.True(t, web.RouteInfos()[0].Stream)
+}
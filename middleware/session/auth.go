@@ -0,0 +1,76 @@
+package session
+
+import (
+	"github.com/rentiansheng/go-api-component/middleware/auth"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+// 登录信息在 session 中的 key
+const (
+	PrincipalIDKey    = "_principal_id"
+	PrincipalNameKey  = "_principal_name"
+	PrincipalRolesKey = "_principal_roles"
+)
+
+// Login 登录成功后调用, 更换 session id 并保存调用方信息, 之后的请求通过 session 认证
+func Login(ctx coreContext.Context, p *coreContext.Principal) errors.Error {
+	s := coreContext.SessionFromContext(ctx)
+	if s == nil {
+		return errors.New(nil, code.SessionStoreErrCode, "session is not enabled")
+	}
+	s.Rotate()
+	s.Set(PrincipalIDKey, p.ID)
+	s.Set(PrincipalNameKey, p.Name)
+	s.Set(PrincipalRolesKey, p.Roles)
+	ctx.SetPrincipal(p)
+	return nil
+}
+
+// Logout 删除 session
+func Logout(ctx coreContext.Context) {
+	if s := coreContext.SessionFromContext(ctx); s != nil {
+		s.Destroy()
+	}
+	ctx.SetPrincipal(nil)
+}
+
+// NewAuthenticator session 认证方式, 名称为 session。session 中有 Login 保存的调用方信息时认证通过
+func NewAuthenticator() auth.Authenticator {
+	return authenticator{}
+}
+
+type authenticator struct{}
+
+func (authenticator) Name() string {
+	return auth.NameSession
+}
+
+func (authenticator) Authenticate(ctx coreContext.Context) (*coreContext.Principal, errors.Error) {
+	s := coreContext.SessionFromContext(ctx)
+	if s == nil {
+		return nil, nil
+	}
+	id, _ := s.Get(PrincipalIDKey)
+	principalID, _ := id.(string)
+	if principalID == "" {
+		return nil, nil
+	}
+	name, _ := s.Get(PrincipalNameKey)
+	p := &coreContext.Principal{ID: principalID, Authenticator: auth.NameSession}
+	p.Name, _ = name.(string)
+	roles, _ := s.Get(PrincipalRolesKey)
+	switch v := roles.(type) {
+	case []string:
+		p.Roles = v
+	case []interface{}:
+		// 文件等存储使用 json 序列化后为 []interface{}
+		for _, r := range v {
+			if role, ok := r.(string); ok {
+				p.Roles = append(p.Roles, role)
+			}
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,82 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// cookieCodec 签名并且可选加密 cookie 中的 session id
+type cookieCodec struct {
+	name    string
+	signKey []byte
+	aead    cipher.AEAD
+}
+
+func newCookieCodec(name, signingKey, encryptionKey string) (*cookieCodec, error) {
+	c := &cookieCodec{name: name, signKey: []byte(signingKey)}
+	if encryptionKey != "" {
+		// 任意长度的 key 通过 sha256 转换为 AES-256 key
+		key := sha256.Sum256([]byte(encryptionKey))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, err
+		}
+		if c.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// encode 格式为 base64(payload).base64(hmac(name|payload)), 加密时 payload 为 nonce+密文
+func (c *cookieCodec) encode(id string) (string, error) {
+	payload := []byte(id)
+	if c.aead != nil {
+		nonce := make([]byte, c.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("generate nonce: %w", err)
+		}
+		payload = c.aead.Seal(nonce, nonce, payload, []byte(c.name))
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	return value + "." + base64.RawURLEncoding.EncodeToString(c.sign(value)), nil
+}
+
+func (c *cookieCodec) decode(cookie string) (string, bool) {
+	value, sig, ok := strings.Cut(cookie, ".")
+	if !ok {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, c.sign(value)) {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", false
+	}
+	if c.aead != nil {
+		if len(payload) < c.aead.NonceSize() {
+			return "", false
+		}
+		nonce, ciphertext := payload[:c.aead.NonceSize()], payload[c.aead.NonceSize():]
+		if payload, err = c.aead.Open(nil, nonce, ciphertext, []byte(c.name)); err != nil {
+			return "", false
+		}
+	}
+	return string(payload), true
+}
+
+func (c *cookieCodec) sign(value string) []byte {
+	h := hmac.New(sha256.New, c.signKey)
+	h.Write([]byte(c.name))
+	h.Write([]byte{'|'})
+	h.Write([]byte(value))
+	return h.Sum(nil)
+}
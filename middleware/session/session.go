@@ -0,0 +1,286 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const (
+	DefaultCookieName      = "session_id"
+	defaultIdleTimeout     = 30 * time.Minute
+	defaultAbsoluteTimeout = 24 * time.Hour
+	minSigningKeyLen       = 32
+)
+
+// Config session 配置
+type Config struct {
+	// CookieName 默认 session_id
+	CookieName string `mapstructure:"cookie_name"`
+	// Path 默认 /
+	Path   string `mapstructure:"path"`
+	Domain string `mapstructure:"domain"`
+	Secure bool   `mapstructure:"secure"`
+	// SameSite lax(默认), strict, none。none 时需要 Secure
+	SameSite string `mapstructure:"same_site"`
+	// SigningKey 签名 cookie 的 key, 至少 32 个字符
+	SigningKey string `mapstructure:"signing_key"`
+	// EncryptionKey 不为空时使用 AES-GCM 加密 cookie
+	EncryptionKey string `mapstructure:"encryption_key"`
+	// IdleTimeout 超过这个时间没有请求时 session 过期, 默认 30m
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// AbsoluteTimeout session 创建后的最长有效时间, 默认 24h
+	AbsoluteTimeout time.Duration `mapstructure:"absolute_timeout"`
+}
+
+// Manager 管理 session 的加载和保存
+type Manager struct {
+	conf     Config
+	sameSite http.SameSite
+	codec    *cookieCodec
+	store    Store
+	now      func() time.Time
+}
+
+var (
+	mu             sync.RWMutex
+	defaultManager *Manager
+)
+
+// SetDefault 启用 session, 通过 middleware 注册的路由在 handler 前加载 session, handler 后保存。m 为 nil 时关闭
+func SetDefault(m *Manager) {
+	mu.Lock()
+	defer mu.Unlock()
+	defaultManager = m
+}
+
+// Default 获取 SetDefault 设置的 Manager, 没有启用时返回 nil
+func Default() *Manager {
+	mu.RLock()
+	defer mu.RUnlock()
+	return defaultManager
+}
+
+func NewManager(conf Config, store Store) (*Manager, error) {
+	if store == nil {
+		return nil, fmt.Errorf("session store is required")
+	}
+	if len(conf.SigningKey) < minSigningKeyLen {
+		return nil, fmt.Errorf("session signing_key must be at least %d characters", minSigningKeyLen)
+	}
+	if conf.CookieName == "" {
+		conf.CookieName = DefaultCookieName
+	}
+	if conf.Path == "" {
+		conf.Path = "/"
+	}
+	if conf.IdleTimeout <= 0 {
+		conf.IdleTimeout = defaultIdleTimeout
+	}
+	if conf.AbsoluteTimeout <= 0 {
+		conf.AbsoluteTimeout = defaultAbsoluteTimeout
+	}
+	m := &Manager{conf: conf, store: store, now: time.Now}
+	switch strings.ToLower(conf.SameSite) {
+	case "", "lax":
+		m.sameSite = http.SameSiteLaxMode
+	case "strict":
+		m.sameSite = http.SameSiteStrictMode
+	case "none":
+		if !conf.Secure {
+			return nil, fmt.Errorf("session same_site none requires secure")
+		}
+		m.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown session same_site %q", conf.SameSite)
+	}
+	codec, err := newCookieCodec(conf.CookieName, conf.SigningKey, conf.EncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("session encryption_key: %w", err)
+	}
+	m.codec = codec
+	return m, nil
+}
+
+// Load 从 cookie 中加载 session 并保存到 ctx。cookie 无效, session 不存在或者过期时使用新的 session
+func (m *Manager) Load(ctx coreContext.Contexts) {
+	now := m.now()
+	s := &session{id: newID(), isNew: true, record: Record{Values: map[string]interface{}{}, CreatedAt: now}}
+	ctx.SetSession(s)
+
+	c, err := ctx.Request().Cookie(m.conf.CookieName)
+	if err != nil {
+		return
+	}
+	s.hadCookie = true
+	id, ok := m.codec.decode(c.Value)
+	if !ok {
+		ctx.Log().Infof("session: invalid cookie %s", m.conf.CookieName)
+		return
+	}
+	record, err := m.store.Load(ctx, id)
+	if err != nil {
+		ctx.Log().Errorf("session: load session failed. err: %s", err.Error())
+		return
+	}
+	if record == nil {
+		return
+	}
+	if now.Sub(record.LastAccess) >= m.conf.IdleTimeout || now.Sub(record.CreatedAt) >= m.conf.AbsoluteTimeout {
+		if err := m.store.Delete(ctx, id); err != nil {
+			ctx.Log().Errorf("session: delete expired session failed. err: %s", err.Error())
+		}
+		return
+	}
+	if record.Values == nil {
+		record.Values = map[string]interface{}{}
+	}
+	s.id, s.isNew, s.record = id, false, *record
+}
+
+// Save 保存 ctx 中的 session 并在 session id 变化时设置 cookie, 需要在写 response 之前调用。
+// 没有数据的新 session 不会保存
+func (m *Manager) Save(ctx coreContext.Contexts) errors.Error {
+	s, ok := ctx.Session().(*session)
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range s.oldIDs {
+		if err := m.store.Delete(ctx, id); err != nil {
+			return errors.New(err, code.SessionStoreErrCode, err.Error())
+		}
+	}
+	s.oldIDs = nil
+
+	if s.destroyed {
+		if s.hadCookie {
+			http.SetCookie(ctx.Response(), m.cookie("", -1, time.Time{}))
+		}
+		return nil
+	}
+	if s.isNew && !s.dirty {
+		return nil
+	}
+
+	now := m.now()
+	if s.record.CreatedAt.IsZero() {
+		s.record.CreatedAt = now
+	}
+	expiresAt := s.record.CreatedAt.Add(m.conf.AbsoluteTimeout)
+	ttl := m.conf.IdleTimeout
+	if remaining := expiresAt.Sub(now); remaining < ttl {
+		ttl = remaining
+	}
+	s.record.LastAccess = now
+	if err := m.store.Save(ctx, s.id, &s.record, ttl); err != nil {
+		return errors.New(err, code.SessionStoreErrCode, err.Error())
+	}
+	if s.isNew || s.rotated {
+		value, err := m.codec.encode(s.id)
+		if err != nil {
+			return errors.New(err, code.SessionStoreErrCode, err.Error())
+		}
+		http.SetCookie(ctx.Response(), m.cookie(value, 0, expiresAt))
+		s.isNew, s.rotated = false, false
+	}
+	s.dirty = false
+	return nil
+}
+
+func (m *Manager) cookie(value string, maxAge int, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.conf.CookieName,
+		Value:    value,
+		Path:     m.conf.Path,
+		Domain:   m.conf.Domain,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Secure:   m.conf.Secure,
+		HttpOnly: true,
+		SameSite: m.sameSite,
+	}
+}
+
+// session 实现 context.Session
+type session struct {
+	mu     sync.Mutex
+	id     string
+	record Record
+	// oldIDs Rotate 前的 id, 保存时删除
+	oldIDs    []string
+	isNew     bool
+	hadCookie bool
+	dirty     bool
+	rotated   bool
+	destroyed bool
+}
+
+func (s *session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *session) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.record.Values[key]
+	return v, ok
+}
+
+func (s *session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record.Values[key] = value
+	s.dirty = true
+	s.destroyed = false
+}
+
+func (s *session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.record.Values, key)
+	s.dirty = true
+}
+
+func (s *session) Rotate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew {
+		s.oldIDs = append(s.oldIDs, s.id)
+	}
+	s.id = newID()
+	s.rotated = true
+	s.dirty = true
+}
+
+// Destroy 删除当前 session, 之后再写入数据时使用新的 session id
+func (s *session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isNew {
+		s.oldIDs = append(s.oldIDs, s.id)
+	}
+	s.id = newID()
+	s.record = Record{Values: map[string]interface{}{}}
+	s.isNew = true
+	s.destroyed = true
+	s.dirty = false
+}
+
+func newID() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
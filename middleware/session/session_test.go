@@ -0,0 +1,213 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/context/contexttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSigningKey = "0123456789abcdef0123456789abcdef"
+
+// request 模拟一次请求, 返回响应中的 session cookie
+func request(t *testing.T, m *Manager, cookie *http.Cookie, fn func(ctx coreContext.Contexts)) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	ctx, w := contexttest.NewContext(req)
+	m.Load(ctx)
+	fn(ctx)
+	require.Nil(t, m.Save(ctx))
+	for _, c := range w.Result().Cookies() {
+		if c.Name == m.conf.CookieName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	for name, encryptionKey := range map[string]string{"signed": "", "encrypted": "enc-key"} {
+		t.Run(name, func(t *testing.T) {
+			store := NewMemoryStore()
+			m, err := NewManager(Config{SigningKey: testSigningKey, EncryptionKey: encryptionKey, Secure: true}, store)
+			require.NoError(t, err)
+
+			// 没有数据时不创建 session
+			assert.Nil(t, request(t, m, nil, func(ctx coreContext.Contexts) {
+				assert.NotEmpty(t, ctx.Session().ID())
+			}))
+
+			var id string
+			cookie := request(t, m, nil, func(ctx coreContext.Contexts) {
+				ctx.Session().Set("cart", "c1")
+				id = ctx.Session().ID()
+			})
+			require.NotNil(t, cookie)
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Secure)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+			// 只签名时 cookie 中是明文 id, 加密后不包含 id
+			assert.Equal(t, encryptionKey == "", strings.HasPrefix(cookie.Value, encodeID(id)+"."))
+
+			// 读取已有的 session, id 没有变化时不重复设置 cookie
+			assert.Nil(t, request(t, m, cookie, func(ctx coreContext.Contexts) {
+				assert.Equal(t, id, ctx.Session().ID())
+				v, ok := ctx.Session().Get("cart")
+				assert.True(t, ok)
+				assert.Equal(t, "c1", v)
+			}))
+
+			// 篡改 cookie
+			forged := *cookie
+			forged.Value = encodeID("other") + cookie.Value[strings.Index(cookie.Value, "."):]
+			request(t, m, &forged, func(ctx coreContext.Contexts) {
+				_, ok := ctx.Session().Get("cart")
+				assert.False(t, ok)
+			})
+
+			// Rotate 更换 id 并删除旧的 session
+			rotated := request(t, m, cookie, func(ctx coreContext.Contexts) {
+				ctx.Session().Rotate()
+			})
+			require.NotNil(t, rotated)
+			assert.NotEqual(t, cookie.Value, rotated.Value)
+			r, _ := store.Load(context.Background(), id)
+			assert.Nil(t, r)
+			request(t, m, rotated, func(ctx coreContext.Contexts) {
+				v, _ := ctx.Session().Get("cart")
+				assert.Equal(t, "c1", v)
+			})
+
+			// Destroy 删除 session 和 cookie
+			cleared := request(t, m, rotated, func(ctx coreContext.Contexts) {
+				ctx.Session().Destroy()
+			})
+			require.NotNil(t, cleared)
+			assert.Equal(t, -1, cleared.MaxAge)
+			request(t, m, rotated, func(ctx coreContext.Contexts) {
+				_, ok := ctx.Session().Get("cart")
+				assert.False(t, ok)
+			})
+		})
+	}
+}
+
+func encodeID(id string) string {
+	c, _ := newCookieCodec(DefaultCookieName, testSigningKey, "")
+	v, _ := c.encode(id)
+	return v[:strings.Index(v, ".")]
+}
+
+func TestManager_Timeout(t *testing.T) {
+	store := NewMemoryStore()
+	m, err := NewManager(Config{SigningKey: testSigningKey, IdleTimeout: 10 * time.Minute, AbsoluteTimeout: time.Hour}, store)
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	store.now = m.now
+
+	cookie := request(t, m, nil, func(ctx coreContext.Contexts) { ctx.Session().Set("k", "v") })
+	require.NotNil(t, cookie)
+	assert.Equal(t, now.Add(time.Hour).Unix(), cookie.Expires.Unix())
+	alive := func() bool {
+		ok := false
+		request(t, m, cookie, func(ctx coreContext.Contexts) { _, ok = ctx.Session().Get("k") })
+		return ok
+	}
+
+	// 每次请求刷新空闲时间
+	for i := 0; i < 5; i++ {
+		now = now.Add(9 * time.Minute)
+		assert.True(t, alive(), i)
+	}
+	// 超过最长有效时间
+	now = now.Add(9 * time.Minute)
+	assert.True(t, alive())
+	now = now.Add(9 * time.Minute)
+	assert.False(t, alive())
+
+	// 空闲超时
+	cookie = request(t, m, nil, func(ctx coreContext.Contexts) { ctx.Session().Set("k", "v") })
+	now = now.Add(11 * time.Minute)
+	assert.False(t, alive())
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	require.NoError(t, store.Save(ctx, "s1", &Record{Values: map[string]interface{}{"n": 1, "roles": []string{"admin"}}, CreatedAt: now}, time.Minute))
+	r, err := store.Load(ctx, "s1")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, float64(1), r.Values["n"])
+	assert.Equal(t, []interface{}{"admin"}, r.Values["roles"])
+
+	_, err = store.Load(ctx, "../s1")
+	assert.Error(t, err)
+
+	now = now.Add(time.Minute)
+	r, err = store.Load(ctx, "s1")
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	require.NoError(t, store.Save(ctx, "s2", &Record{}, time.Second))
+	now = now.Add(time.Second)
+	require.NoError(t, store.Cleanup())
+	require.NoError(t, store.Delete(ctx, "s2"))
+}
+
+func TestLogin(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	m, err := NewManager(Config{SigningKey: testSigningKey}, store)
+	require.NoError(t, err)
+	a := NewAuthenticator()
+
+	anonymous := request(t, m, nil, func(ctx coreContext.Contexts) { ctx.Session().Set("lang", "zh") })
+	require.NotNil(t, anonymous)
+	request(t, m, anonymous, func(ctx coreContext.Contexts) {
+		p, err := a.Authenticate(ctx)
+		assert.Nil(t, err)
+		assert.Nil(t, p)
+	})
+
+	// 登录后更换 session id
+	cookie := request(t, m, anonymous, func(ctx coreContext.Contexts) {
+		require.Nil(t, Login(ctx, &coreContext.Principal{ID: "u1", Name: "tom", Roles: []string{"admin"}}))
+	})
+	require.NotNil(t, cookie)
+	assert.NotEqual(t, anonymous.Value, cookie.Value)
+
+	request(t, m, cookie, func(ctx coreContext.Contexts) {
+		p, err := a.Authenticate(ctx)
+		require.Nil(t, err)
+		require.NotNil(t, p)
+		assert.Equal(t, "u1", p.ID)
+		assert.Equal(t, "tom", p.Name)
+		assert.Equal(t, []string{"admin"}, p.Roles)
+		Logout(ctx)
+	})
+	request(t, m, cookie, func(ctx coreContext.Contexts) {
+		p, _ := a.Authenticate(ctx)
+		assert.Nil(t, p)
+	})
+
+	_, err = NewManager(Config{SigningKey: "short"}, store)
+	assert.Error(t, err)
+	_, err = NewManager(Config{SigningKey: testSigningKey, SameSite: "none"}, store)
+	assert.Error(t, err)
+}
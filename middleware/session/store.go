@@ -0,0 +1,208 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const memoryStoreSweepInterval = time.Minute
+
+// Record session 中保存的数据
+type Record struct {
+	Values     map[string]interface{} `json:"values"`
+	CreatedAt  time.Time              `json:"created_at"`
+	LastAccess time.Time              `json:"last_access"`
+}
+
+// Store session 存储, 实现其他存储(例如 redis)时实现这个接口
+type Store interface {
+	// Load 获取 session, 不存在或者已经过期时返回 nil, nil
+	Load(ctx context.Context, id string) (*Record, error)
+	// Save 保存 session, ttl 后过期
+	Save(ctx context.Context, id string, r *Record, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore 内存存储, 只适合单实例部署
+type MemoryStore struct {
+	mu        sync.Mutex
+	items     map[string]memoryItem
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryItem struct {
+	record    Record
+	expiresAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem), now: time.Now}
+}
+
+func (s *MemoryStore) Load(ctx context.Context, id string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.items[id]
+	if !ok {
+		return nil, nil
+	}
+	if !s.now().Before(item.expiresAt) {
+		delete(s.items, id)
+		return nil, nil
+	}
+	r := item.record
+	r.Values = copyValues(item.record.Values)
+	return &r, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, id string, r *Record, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	// 定期清理过期的 session
+	if now.Sub(s.lastSweep) >= memoryStoreSweepInterval {
+		s.lastSweep = now
+		for k, item := range s.items {
+			if !now.Before(item.expiresAt) {
+				delete(s.items, k)
+			}
+		}
+	}
+	record := *r
+	record.Values = copyValues(r.Values)
+	s.items[id] = memoryItem{record: record, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, id)
+	return nil
+}
+
+// FileStore 文件存储, 每个 session 一个 json 文件。数据使用 json 序列化, 数字读取后为 float64
+type FileStore struct {
+	dir string
+	now func() time.Time
+}
+
+type fileItem struct {
+	Record    Record    `json:"record"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewFileStore dir 不存在时创建
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+	return &FileStore{dir: dir, now: time.Now}, nil
+}
+
+func (s *FileStore) path(id string) (string, error) {
+	// id 由 session 组件生成, 这里防止非法 id 访问目录外的文件
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", fmt.Errorf("invalid session id")
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+func (s *FileStore) Load(ctx context.Context, id string) (*Record, error) {
+	file, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read session file: %w", err)
+	}
+	item := fileItem{}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("parse session file: %w", err)
+	}
+	if !s.now().Before(item.ExpiresAt) {
+		_ = os.Remove(file)
+		return nil, nil
+	}
+	return &item.Record, nil
+}
+
+func (s *FileStore) Save(ctx context.Context, id string, r *Record, ttl time.Duration) error {
+	file, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(fileItem{Record: *r, ExpiresAt: s.now().Add(ttl)})
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	// 先写临时文件再重命名, 避免并发读到不完整的文件
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write session file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write session file: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	file, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete session file: %w", err)
+	}
+	return nil
+}
+
+// Cleanup 删除过期的 session 文件, 需要调用方定期执行
+func (s *FileStore) Cleanup() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		item := fileItem{}
+		if err := json.Unmarshal(data, &item); err != nil || !now.Before(item.ExpiresAt) {
+			_ = os.Remove(file)
+		}
+	}
+	return nil
+}
+
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		cp[k] = v
+	}
+	return cp
+}
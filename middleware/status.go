@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+var (
+	httpStatusMu sync.RWMutex
+	// httpStatuses 错误码对应的 http 状态码, 没有注册的错误码返回 200
+	httpStatuses = map[int32]int{
+		code.RequestBodyTooLargeErrCode:  http.StatusRequestEntityTooLarge,
+		code.JSONTooComplexErrCode:       http.StatusRequestEntityTooLarge,
+		code.UnsupportedMediaTypeErrCode: http.StatusUnsupportedMediaType,
+		code.NotAcceptableErrCode:        http.StatusNotAcceptable,
+		code.StreamingUnsupportedErrCode: http.StatusInternalServerError,
+		code.StreamFileNotFoundErrCode:   http.StatusNotFound,
+		code.StreamFileErrCode:           http.StatusInternalServerError,
+		code.UnauthenticatedErrCode:      http.StatusUnauthorized,
+		code.InvalidCredentialsErrCode:   http.StatusUnauthorized,
+		code.UnknownAuthenticatorErrCode: http.StatusInternalServerError,
+		code.TokenExpiredErrCode:         http.StatusUnauthorized,
+		code.PermissionDeniedErrCode:     http.StatusForbidden,
+		code.APIKeyQuotaExceededErrCode:  http.StatusTooManyRequests,
+		code.SessionStoreErrCode:         http.StatusInternalServerError,
+		code.CSRFCheckFailedErrCode:      http.StatusForbidden,
+		code.RateLimitedErrCode:          http.StatusTooManyRequests,
+		code.ServerOverloadedErrCode:     http.StatusServiceUnavailable,
+		code.RequestTimeoutErrCode:       http.StatusGatewayTimeout,
+	}
+)
+
+// RegisterHTTPStatus 注册错误码对应的 http 状态码, 返回该错误码时使用对应的状态码, 响应体不变
+func RegisterHTTPStatus(errCode int32, status int) {
+	httpStatusMu.Lock()
+	defer httpStatusMu.Unlock()
+	httpStatuses[errCode] = status
+}
+
+// HTTPStatus 错误码对应的 http 状态码
+func HTTPStatus(errCode int32) int {
+	httpStatusMu.RLock()
+	defer httpStatusMu.RUnlock()
+	if status, ok := httpStatuses[errCode]; ok {
+		return status
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,219 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+)
+
+// RequestTimeoutHeader 调用方剩余的超时时间, 例如 1500ms, 2s。没有单位时为毫秒
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+const defaultTimeoutKey = "go-api-component/default-timeout"
+
+var defaultTimeout atomic.Int64
+
+// SetDefaultTimeout 设置全局的默认超时时间, 用于没有指定 Route.Timeout 的路由, 小于等于 0 时不限制。
+// gin engine 上使用了 DefaultTimeoutHandler 时优先使用 engine 的设置
+func SetDefaultTimeout(d time.Duration) {
+	defaultTimeout.Store(int64(d))
+}
+
+// DefaultTimeout 获取 SetDefaultTimeout 设置的超时时间
+func DefaultTimeout() time.Duration {
+	return time.Duration(defaultTimeout.Load())
+}
+
+// DefaultTimeoutHandler 为当前 gin engine 设置默认超时时间, 只对该 engine 生效, 多个 server 之间互不影响。
+// 需要在注册路由之前 Use, server.Server.RequestTimeout 不为 0 时由 server 设置
+func DefaultTimeoutHandler(d time.Duration) gin.HandlerFunc {
+	return func(g *gin.Context) {
+		g.Set(defaultTimeoutKey, d)
+		g.Next()
+	}
+}
+
+// routeTimeout 路由没有指定超时时间时使用 engine 或者全局的默认超时时间
+func routeTimeout(g *gin.Context, d time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	if v, exists := g.Get(defaultTimeoutKey); exists {
+		d, _ = v.(time.Duration)
+		return d
+	}
+	return DefaultTimeout()
+}
+
+// requestTimeout 请求的超时时间。max 为路由的超时时间, 请求头中的超时时间只能缩短 max, max 为 0 时不限制
+func requestTimeout(r *http.Request, max time.Duration) time.Duration {
+	d, ok := ParseRequestTimeout(r.Header.Get(RequestTimeoutHeader))
+	if !ok || (max > 0 && d > max) {
+		return max
+	}
+	return d
+}
+
+// ParseRequestTimeout 解析 RequestTimeoutHeader, 值无效或者小于等于 0 时返回 false
+func ParseRequestTimeout(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	return d, d > 0
+}
+
+// SetRequestTimeoutHeader 将 ctx 剩余的超时时间写入 header, 调用下游服务时传递截止时间。ctx 没有截止时间时不设置
+func SetRequestTimeoutHeader(ctx context.Context, h http.Header) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 1 {
+		remaining = 1
+	}
+	h.Set(RequestTimeoutHeader, strconv.FormatInt(remaining, 10)+"ms")
+}
+
+// callWithTimeout 在单独的 goroutine 中执行 handler, ctx 结束时立即返回 finished = false, 不等待 handler。
+// handler 使用 gin.Context 的副本, 写入的响应经过 timeoutWriter, 超时后丢弃 handler 的写入和结果。
+// 按时返回或者 panic 时返回 handler 使用的 Contexts, 其中包含 SetData, SSE 等设置的结果, panic 由调用方在替换 ctx 之后重新抛出
+func callWithTimeout(g *gin.Context, ctx coreContext.Contexts, h Handler) (coreContext.Contexts, errors.Error, bool, interface{}) {
+	tw := newTimeoutWriter(g.Writer)
+	hctx := coreContext.DetachGinContext(ctx, tw)
+	done := make(chan errors.Error, 1)
+	panicked := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				panicked <- e
+			}
+		}()
+		done <- h(hctx)
+	}()
+
+	select {
+	case err := <-done:
+		tw.commit()
+		coreContext.AttachGinContext(hctx, g)
+		return hctx, err, true, nil
+	case e := <-panicked:
+		tw.commit()
+		coreContext.AttachGinContext(hctx, g)
+		return hctx, nil, true, e
+	case <-ctx.Done():
+		tw.discard()
+		go func() {
+			select {
+			case <-done:
+			case e := <-panicked:
+				hctx.Log().Panicf("panic after request timeout. err: %#v", e)
+			}
+			// handler 结束后关闭它设置的文件流和 SSE
+			closeStream(hctx)
+		}()
+		return ctx, nil, false, nil
+	}
+}
+
+// timeoutWriter handler 在单独的 goroutine 中使用的 ResponseWriter。header 在写入时才同步到请求的 ResponseWriter,
+// discard 之后丢弃所有写入, 不再访问请求的 ResponseWriter
+type timeoutWriter struct {
+	gin.ResponseWriter
+	mu        sync.Mutex
+	header    http.Header
+	discarded bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{ResponseWriter: w, header: w.Header().Clone()}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(statusCode int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return
+	}
+	tw.syncHeader()
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *timeoutWriter) WriteHeaderNow() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return
+	}
+	tw.syncHeader()
+	tw.ResponseWriter.WriteHeaderNow()
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.syncHeader()
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *timeoutWriter) WriteString(s string) (int, error) {
+	return tw.Write([]byte(s))
+}
+
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return
+	}
+	tw.syncHeader()
+	tw.ResponseWriter.Flush()
+}
+
+// commit handler 按时返回, 同步 handler 设置的 header
+func (tw *timeoutWriter) commit() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.syncHeader()
+}
+
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.discarded = true
+}
+
+func (tw *timeoutWriter) syncHeader() {
+	dst := tw.ResponseWriter.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+}
@@ -0,0 +1,122 @@
+package concurrency
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	AlgorithmGradient = "gradient"
+	AlgorithmAIMD     = "aimd"
+)
+
+// Algorithm 根据请求的延迟调整并发限制, 调用方需要保证串行调用
+type Algorithm interface {
+	// Limit 当前的并发限制
+	Limit() int
+	// Update 请求结束时调用, inFlight 为请求开始时的并发数, dropped 为请求超时或者因为过载失败
+	Update(rtt time.Duration, inFlight int, dropped bool)
+}
+
+// AIMD 加性增, 乘性减。延迟超过 LatencyThreshold 或者请求失败时按 BackoffRatio 降低限制,
+// 否则并发数达到限制的一半时限制加 1
+type AIMD struct {
+	limit            float64
+	min, max         float64
+	latencyThreshold time.Duration
+	backoffRatio     float64
+}
+
+// NewAIMD backoffRatio 不在 (0, 1) 之间时使用 0.9
+func NewAIMD(initial, min, max int, latencyThreshold time.Duration, backoffRatio float64) *AIMD {
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.9
+	}
+	return &AIMD{
+		limit:            float64(initial),
+		min:              float64(min),
+		max:              float64(max),
+		latencyThreshold: latencyThreshold,
+		backoffRatio:     backoffRatio,
+	}
+}
+
+func (a *AIMD) Limit() int {
+	return int(a.limit)
+}
+
+func (a *AIMD) Update(rtt time.Duration, inFlight int, dropped bool) {
+	switch {
+	case dropped || (a.latencyThreshold > 0 && rtt > a.latencyThreshold):
+		a.limit = math.Max(a.min, math.Floor(a.limit*a.backoffRatio))
+	case float64(inFlight)*2 >= a.limit:
+		a.limit = math.Min(a.max, a.limit+1)
+	}
+}
+
+// Gradient 比较长期和短期的平均延迟, 短期延迟升高说明出现排队, 按比例降低限制; 延迟平稳时逐渐增加限制。
+// 参考 Netflix concurrency-limits 的 Gradient2
+type Gradient struct {
+	limit     float64
+	min, max  float64
+	tolerance float64
+	smoothing float64
+	longRTT   ewma
+	shortRTT  ewma
+}
+
+// NewGradient tolerance 为允许的延迟升高倍数, 小于 1 时使用 1.5
+func NewGradient(initial, min, max int, tolerance float64) *Gradient {
+	if tolerance < 1 {
+		tolerance = 1.5
+	}
+	return &Gradient{
+		limit:     float64(initial),
+		min:       float64(min),
+		max:       float64(max),
+		tolerance: tolerance,
+		smoothing: 0.2,
+		longRTT:   ewma{alpha: 2.0 / 601},
+		shortRTT:  ewma{alpha: 2.0 / 11},
+	}
+}
+
+func (g *Gradient) Limit() int {
+	return int(g.limit)
+}
+
+func (g *Gradient) Update(rtt time.Duration, inFlight int, dropped bool) {
+	if dropped {
+		g.limit = math.Max(g.min, g.limit*0.9)
+		return
+	}
+	short := g.shortRTT.add(float64(rtt))
+	long := g.longRTT.add(float64(rtt))
+	// 长时间过载后长期延迟会跟着升高, 按比例回落, 避免一直维持在高延迟
+	if long/short > 2 {
+		g.longRTT.value *= 0.95
+		long = g.longRTT.value
+	}
+	// 请求量没有达到限制的一半时不增加限制
+	if float64(inFlight)*2 < g.limit {
+		return
+	}
+	gradient := math.Max(0.5, math.Min(1, g.tolerance*long/short))
+	newLimit := g.limit*gradient + math.Sqrt(g.limit)
+	newLimit = g.limit*(1-g.smoothing) + newLimit*g.smoothing
+	g.limit = math.Max(g.min, math.Min(g.max, newLimit))
+}
+
+type ewma struct {
+	alpha float64
+	value float64
+}
+
+func (e *ewma) add(v float64) float64 {
+	if e.value == 0 {
+		e.value = v
+	} else {
+		e.value = e.value*(1-e.alpha) + v*e.alpha
+	}
+	return e.value
+}
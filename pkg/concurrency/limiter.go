@@ -0,0 +1,163 @@
+package concurrency
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority 路由的优先级, 过载时优先拒绝低优先级的请求
+type Priority int
+
+const (
+	// PriorityNormal 默认优先级, 可以使用 90% 的并发限制
+	PriorityNormal Priority = iota
+	// PriorityLow 可以使用 50% 的并发限制, 例如导出, 报表
+	PriorityLow
+	// PriorityHigh 可以使用全部的并发限制
+	PriorityHigh
+	// PriorityCritical 不受并发限制, 例如健康检查, 登录
+	PriorityCritical
+)
+
+var priorityNames = map[Priority]string{
+	PriorityNormal:   "normal",
+	PriorityLow:      "low",
+	PriorityHigh:     "high",
+	PriorityCritical: "critical",
+}
+
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("priority(%d)", int(p))
+}
+
+// share 优先级可以使用的并发限制比例
+func (p Priority) share() float64 {
+	switch p {
+	case PriorityLow:
+		return 0.5
+	case PriorityHigh, PriorityCritical:
+		return 1
+	default:
+		return 0.9
+	}
+}
+
+// Config 并发限制配置
+type Config struct {
+	// Algorithm gradient(默认) 或者 aimd
+	Algorithm    string `mapstructure:"algorithm"`
+	InitialLimit int    `mapstructure:"initial_limit"`
+	MinLimit     int    `mapstructure:"min_limit"`
+	MaxLimit     int    `mapstructure:"max_limit"`
+	// Tolerance gradient 允许的延迟升高倍数, 默认 1.5
+	Tolerance float64 `mapstructure:"tolerance"`
+	// LatencyThreshold aimd 延迟超过这个值时降低限制, 默认 1s
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold"`
+	// BackoffRatio aimd 降低限制的比例, 默认 0.9
+	BackoffRatio float64 `mapstructure:"backoff_ratio"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.Algorithm == "" {
+		c.Algorithm = AlgorithmGradient
+	}
+	if c.MinLimit <= 0 {
+		c.MinLimit = 10
+	}
+	if c.MaxLimit <= 0 {
+		c.MaxLimit = 1000
+	}
+	if c.InitialLimit <= 0 {
+		c.InitialLimit = 100
+	}
+	if c.LatencyThreshold <= 0 {
+		c.LatencyThreshold = time.Second
+	}
+	return c
+}
+
+// Validate 校验配置
+func (c Config) Validate() error {
+	c = c.withDefaults()
+	switch strings.ToLower(c.Algorithm) {
+	case AlgorithmGradient, AlgorithmAIMD:
+	default:
+		return fmt.Errorf("unknown concurrency algorithm %q", c.Algorithm)
+	}
+	if c.MinLimit > c.MaxLimit || c.InitialLimit < c.MinLimit || c.InitialLimit > c.MaxLimit {
+		return fmt.Errorf("concurrency limits must satisfy min_limit <= initial_limit <= max_limit")
+	}
+	return nil
+}
+
+// Limiter 自适应并发限制
+type Limiter struct {
+	mu       sync.Mutex
+	algo     Algorithm
+	inFlight int
+	now      func() time.Time
+}
+
+// New 根据配置创建 Limiter
+func New(conf Config) (*Limiter, error) {
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	conf = conf.withDefaults()
+	var algo Algorithm
+	if strings.ToLower(conf.Algorithm) == AlgorithmAIMD {
+		algo = NewAIMD(conf.InitialLimit, conf.MinLimit, conf.MaxLimit, conf.LatencyThreshold, conf.BackoffRatio)
+	} else {
+		algo = NewGradient(conf.InitialLimit, conf.MinLimit, conf.MaxLimit, conf.Tolerance)
+	}
+	return NewWithAlgorithm(algo), nil
+}
+
+// NewWithAlgorithm 使用自定义的算法
+func NewWithAlgorithm(algo Algorithm) *Limiter {
+	return &Limiter{algo: algo, now: time.Now}
+}
+
+// Acquire 获取执行请求的许可, 超过优先级可以使用的并发限制时返回 false。
+// 成功时需要在请求结束后调用 release, dropped 为请求超时或者因为过载失败
+func (l *Limiter) Acquire(p Priority) (release func(dropped bool), ok bool) {
+	l.mu.Lock()
+	if p != PriorityCritical && float64(l.inFlight) >= float64(l.algo.Limit())*p.share() {
+		l.mu.Unlock()
+		return nil, false
+	}
+	l.inFlight++
+	inFlight := l.inFlight
+	l.mu.Unlock()
+
+	start := l.now()
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() {
+			rtt := l.now().Sub(start)
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.inFlight--
+			l.algo.Update(rtt, inFlight, dropped)
+		})
+	}, true
+}
+
+// Limit 当前的并发限制
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.algo.Limit()
+}
+
+// InFlight 当前正在处理的请求数
+func (l *Limiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
@@ -0,0 +1,95 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_Priority(t *testing.T) {
+	l := NewWithAlgorithm(NewAIMD(10, 1, 10, time.Second, 0.5))
+
+	var releases []func(bool)
+	for i := 0; i < 5; i++ {
+		release, ok := l.Acquire(PriorityLow)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	// low 只能使用 50%
+	_, ok := l.Acquire(PriorityLow)
+	assert.False(t, ok)
+	for i := 0; i < 4; i++ {
+		release, ok := l.Acquire(PriorityNormal)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+	_, ok = l.Acquire(PriorityNormal)
+	assert.False(t, ok)
+	release, ok := l.Acquire(PriorityHigh)
+	require.True(t, ok)
+	releases = append(releases, release)
+	_, ok = l.Acquire(PriorityHigh)
+	assert.False(t, ok)
+	release, ok = l.Acquire(PriorityCritical)
+	require.True(t, ok)
+	releases = append(releases, release)
+	assert.Equal(t, 11, l.InFlight())
+
+	for _, release := range releases {
+		release(false)
+		// 重复调用无效
+		release(false)
+	}
+	assert.Equal(t, 0, l.InFlight())
+}
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(10, 5, 12, 100*time.Millisecond, 0.5)
+	a.Update(10*time.Millisecond, 5, false)
+	assert.Equal(t, 11, a.Limit())
+	// 并发数没有达到限制的一半时不增加
+	a.Update(10*time.Millisecond, 1, false)
+	assert.Equal(t, 11, a.Limit())
+	a.Update(10*time.Millisecond, 11, false)
+	a.Update(10*time.Millisecond, 11, false)
+	assert.Equal(t, 12, a.Limit())
+
+	a.Update(time.Second, 11, false)
+	assert.Equal(t, 6, a.Limit())
+	a.Update(10*time.Millisecond, 11, true)
+	assert.Equal(t, 5, a.Limit())
+}
+
+func TestGradient(t *testing.T) {
+	g := NewGradient(20, 10, 100, 0)
+	for i := 0; i < 100; i++ {
+		g.Update(10*time.Millisecond, g.Limit(), false)
+	}
+	grown := g.Limit()
+	assert.Greater(t, grown, 20)
+
+	// 延迟升高后降低限制
+	for i := 0; i < 50; i++ {
+		g.Update(100*time.Millisecond, g.Limit(), false)
+	}
+	assert.Less(t, g.Limit(), grown)
+	assert.GreaterOrEqual(t, g.Limit(), 10)
+
+	g.Update(time.Millisecond, 0, true)
+	assert.GreaterOrEqual(t, g.Limit(), 10)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Algorithm: "AIMD"}.Validate())
+	assert.Error(t, Config{Algorithm: "vegas"}.Validate())
+	assert.Error(t, Config{MinLimit: 50, MaxLimit: 20}.Validate())
+	assert.Error(t, Config{InitialLimit: 2000}.Validate())
+
+	l, err := New(Config{Algorithm: AlgorithmAIMD, InitialLimit: 20, MinLimit: 5, MaxLimit: 50})
+	require.NoError(t, err)
+	assert.Equal(t, 20, l.Limit())
+	assert.Equal(t, "critical", PriorityCritical.String())
+}
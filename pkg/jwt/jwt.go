@@ -0,0 +1,412 @@
+package jwt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+
+	defaultClockSkew = 30 * time.Second
+)
+
+var (
+	ErrMalformed   = errors.New("jwt: malformed token")
+	ErrAlgorithm   = errors.New("jwt: algorithm not allowed")
+	ErrUnknownKey  = errors.New("jwt: no key found")
+	ErrSignature   = errors.New("jwt: invalid signature")
+	ErrExpired     = errors.New("jwt: token is expired")
+	ErrNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrMissingExp  = errors.New("jwt: exp is required")
+	ErrIssuer      = errors.New("jwt: invalid issuer")
+	ErrAudience    = errors.New("jwt: invalid audience")
+)
+
+// Config 验证 token 的配置, key 可以来自 secret, 公钥文件和 JWKS 文件
+type Config struct {
+	// Algorithms 允许的算法, 默认为配置的 key 对应的算法
+	Algorithms []string `mapstructure:"algorithms"`
+	// Secret HS256 的密钥
+	Secret string `mapstructure:"secret"`
+	// PublicKeyFile PEM 格式的 RSA/EC 公钥或者证书, 用于 RS256/ES256
+	PublicKeyFile string `mapstructure:"public_key_file"`
+	// KeyID Secret 和 PublicKeyFile 对应的 kid, 为空时匹配所有 token
+	KeyID string `mapstructure:"key_id"`
+	// JWKSFile 本地 JWKS 文件, 修改后自动重新加载
+	JWKSFile           string        `mapstructure:"jwks_file"`
+	JWKSReloadInterval time.Duration `mapstructure:"jwks_reload_interval"`
+	// Issuer 不为空时校验 iss
+	Issuer string `mapstructure:"issuer"`
+	// Audience 不为空时 aud 需要包含其中一个
+	Audience []string `mapstructure:"audience"`
+	// ClockSkew 校验 exp, nbf, iat 时允许的时钟误差, 默认 30s
+	ClockSkew time.Duration `mapstructure:"clock_skew"`
+	// RequireExp token 必须包含 exp
+	RequireExp bool `mapstructure:"require_exp"`
+}
+
+// Claims token 中的 claims
+type Claims map[string]interface{}
+
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+func (c Claims) ID() string {
+	s, _ := c["jti"].(string)
+	return s
+}
+
+// Audience aud 可以是字符串或者字符串数组
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []string:
+		return aud
+	case []interface{}:
+		result := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func (c Claims) ExpiresAt() (time.Time, bool) {
+	return c.time("exp")
+}
+
+func (c Claims) NotBefore() (time.Time, bool) {
+	return c.time("nbf")
+}
+
+func (c Claims) IssuedAt() (time.Time, bool) {
+	return c.time("iat")
+}
+
+// Strings 获取字符串数组类型的 claim, 例如 roles。值为字符串时按空格分隔, 例如 scope
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+		if f, err := v.Float64(); err == nil {
+			return time.Unix(int64(f), 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verifier 校验 token 的签名和 claims
+type Verifier struct {
+	keys       KeySet
+	algorithms map[string]struct{}
+	issuer     string
+	audience   []string
+	clockSkew  time.Duration
+	requireExp bool
+	now        func() time.Time
+}
+
+// NewVerifier 根据配置创建 Verifier, 至少需要配置一个 key
+func NewVerifier(conf Config) (*Verifier, error) {
+	var sets multiKeySet
+	var static StaticKeySet
+	if conf.Secret != "" {
+		static = append(static, Key{ID: conf.KeyID, Algorithm: HS256, Key: []byte(conf.Secret)})
+	}
+	if conf.PublicKeyFile != "" {
+		data, err := os.ReadFile(conf.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt public_key_file: %w", err)
+		}
+		pub, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("parse jwt public_key_file: %w", err)
+		}
+		alg, err := algorithmOf(pub)
+		if err != nil {
+			return nil, err
+		}
+		static = append(static, Key{ID: conf.KeyID, Algorithm: alg, Key: pub})
+	}
+	if len(static) > 0 {
+		sets = append(sets, static)
+	}
+	if conf.JWKSFile != "" {
+		fileSet, err := NewFileKeySet(conf.JWKSFile, conf.JWKSReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, fileSet)
+	}
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("jwt: secret, public_key_file or jwks_file is required")
+	}
+
+	algorithms := conf.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{HS256, RS256, ES256}
+	}
+	return NewVerifierWithKeys(sets, algorithms, conf)
+}
+
+// NewVerifierWithKeys 使用自定义的 KeySet, 忽略 conf 中的 key 配置
+func NewVerifierWithKeys(keys KeySet, algorithms []string, conf Config) (*Verifier, error) {
+	v := &Verifier{
+		keys:       keys,
+		algorithms: make(map[string]struct{}),
+		issuer:     conf.Issuer,
+		audience:   conf.Audience,
+		clockSkew:  conf.ClockSkew,
+		requireExp: conf.RequireExp,
+		now:        time.Now,
+	}
+	if v.clockSkew <= 0 {
+		v.clockSkew = defaultClockSkew
+	}
+	for _, alg := range algorithms {
+		switch alg {
+		case HS256, RS256, ES256:
+			v.algorithms[alg] = struct{}{}
+		default:
+			return nil, fmt.Errorf("jwt: unsupported algorithm %s", alg)
+		}
+	}
+	return v, nil
+}
+
+// Verify 校验签名, exp, nbf, iss, aud, 返回 token 中的 claims
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	if _, ok := v.algorithms[h.Alg]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAlgorithm, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	keys := v.keys.Keys(h.Kid)
+	matched := false
+	verified := false
+	for _, key := range keys {
+		// key 的算法必须与 token 一致, 避免使用公钥作为 HMAC 密钥等算法混淆攻击
+		if key.Algorithm != h.Alg {
+			continue
+		}
+		matched = true
+		if verifySignature(h.Alg, key.Key, signed, signature) {
+			verified = true
+			break
+		}
+	}
+	if !matched {
+		return nil, fmt.Errorf("%w: kid %q, alg %s", ErrUnknownKey, h.Kid, h.Alg)
+	}
+	if !verified {
+		return nil, ErrSignature
+	}
+
+	claims := Claims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformed
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	if exp, ok := claims.ExpiresAt(); ok {
+		if !now.Before(exp.Add(v.clockSkew)) {
+			return ErrExpired
+		}
+	} else if v.requireExp {
+		return ErrMissingExp
+	}
+	if nbf, ok := claims.NotBefore(); ok && now.Add(v.clockSkew).Before(nbf) {
+		return ErrNotValidYet
+	}
+	if iat, ok := claims.IssuedAt(); ok && now.Add(v.clockSkew).Before(iat) {
+		return ErrNotValidYet
+	}
+	if v.issuer != "" && claims.Issuer() != v.issuer {
+		return ErrIssuer
+	}
+	if len(v.audience) > 0 && !containsAny(claims.Audience(), v.audience) {
+		return ErrAudience
+	}
+	return nil
+}
+
+func containsAny(values, targets []string) bool {
+	for _, v := range values {
+		for _, t := range targets {
+			if v == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func verifySignature(alg string, key interface{}, signed, signature []byte) bool {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+	case ES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(pub, digest[:], r, s)
+	}
+	return false
+}
+
+func sign(alg string, key interface{}, signed []byte) ([]byte, error) {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write(signed)
+		return mac.Sum(nil), nil
+	case RS256:
+		return rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:])
+	case ES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// r, s 各 32 字节, 不足时前面补 0
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported algorithm %s", alg)
+}
+
+// algorithmOf 根据公钥或者私钥类型获取算法
+func algorithmOf(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case []byte:
+		return HS256, nil
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return RS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("jwt: ES256 requires a P-256 key")
+		}
+		return ES256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("jwt: ES256 requires a P-256 key")
+		}
+		return ES256, nil
+	}
+	return "", fmt.Errorf("jwt: unsupported key type %T", key)
+}
+
+// checkKey 校验 key 的类型与算法是否一致
+func checkKey(alg string, key interface{}) error {
+	keyAlg, err := algorithmOf(key)
+	if err != nil {
+		return err
+	}
+	if keyAlg != alg {
+		return fmt.Errorf("jwt: key type %T does not match algorithm %s", key, alg)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, pub *rsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "n": b64(pub.N.Bytes()), "e": b64(big.NewInt(int64(pub.E)).Bytes())}
+}
+
+func ecJWK(kid string, pub *ecdsa.PublicKey) map[string]string {
+	return map[string]string{"kty": "EC", "kid": kid, "crv": "P-256", "x": b64(pub.X.FillBytes(make([]byte, 32))), "y": b64(pub.Y.FillBytes(make([]byte, 32)))}
+}
+
+func writeJWKS(t *testing.T, file string, keys ...map[string]string) {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, data, 0600))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, jwks, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey))
+	verifier, err := NewVerifier(Config{Secret: "hs-secret", KeyID: "hs-1", JWKSFile: jwks, Issuer: "auth", Audience: []string{"api"}})
+	require.NoError(t, err)
+
+	signers := map[string]struct {
+		key interface{}
+		kid string
+	}{
+		HS256: {[]byte("hs-secret"), "hs-1"},
+		RS256: {rsaKey, "rsa-1"},
+		ES256: {ecKey, "ec-1"},
+	}
+	for alg, s := range signers {
+		t.Run(alg, func(t *testing.T) {
+			signer, err := NewSignerWithKey(SignerConfig{KeyID: s.kid, Issuer: "auth", Audience: []string{"api"}, TTL: time.Hour}, s.key)
+			require.NoError(t, err)
+			token, err := signer.Sign(Claims{"sub": "u1", "roles": []string{"admin"}})
+			require.NoError(t, err)
+
+			claims, err := verifier.Verify(token)
+			require.NoError(t, err)
+			assert.Equal(t, "u1", claims.Subject())
+			assert.Equal(t, []string{"admin"}, claims.Strings("roles"))
+			assert.Equal(t, []string{"api"}, claims.Audience())
+			assert.NotEmpty(t, claims.ID())
+			exp, ok := claims.ExpiresAt()
+			assert.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(time.Hour), exp, 5*time.Second)
+
+			// 篡改 payload
+			parts := strings.Split(token, ".")
+			parts[1] = b64([]byte(`{"sub":"admin","iss":"auth","aud":"api"}`))
+			_, err = verifier.Verify(strings.Join(parts, "."))
+			assert.ErrorIs(t, err, ErrSignature)
+		})
+	}
+}
+
+func TestVerify_Claims(t *testing.T) {
+	verifier, err := NewVerifier(Config{Secret: "secret", Issuer: "auth", Audience: []string{"api", "web"}, ClockSkew: time.Minute, RequireExp: true})
+	require.NoError(t, err)
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	verifier.now = func() time.Time { return now }
+	signer, err := NewSignerWithKey(SignerConfig{}, []byte("secret"))
+	require.NoError(t, err)
+	signer.now = verifier.now
+
+	base := func(extra Claims) Claims {
+		c := Claims{"iss": "auth", "aud": []string{"web"}, "exp": now.Add(time.Hour).Unix()}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	tests := []struct {
+		name   string
+		claims Claims
+		err    error
+	}{
+		{"valid", base(nil), nil},
+		{"expired in skew", base(Claims{"exp": now.Add(-30 * time.Second).Unix()}), nil},
+		{"expired", base(Claims{"exp": now.Add(-2 * time.Minute).Unix()}), ErrExpired},
+		{"missing exp", Claims{"iss": "auth", "aud": "api"}, ErrMissingExp},
+		{"nbf in skew", base(Claims{"nbf": now.Add(30 * time.Second).Unix()}), nil},
+		{"not valid yet", base(Claims{"nbf": now.Add(2 * time.Minute).Unix()}), ErrNotValidYet},
+		{"issued in future", base(Claims{"iat": now.Add(2 * time.Minute).Unix()}), ErrNotValidYet},
+		{"issuer", base(Claims{"iss": "other"}), ErrIssuer},
+		{"audience", base(Claims{"aud": "admin"}), ErrAudience},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := signer.Sign(tt.claims)
+			require.NoError(t, err)
+			_, err = verifier.Verify(token)
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerify_Algorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	pubFile := filepath.Join(t.TempDir(), "pub.pem")
+	require.NoError(t, os.WriteFile(pubFile, pubPEM, 0600))
+
+	verifier, err := NewVerifier(Config{PublicKeyFile: pubFile})
+	require.NoError(t, err)
+
+	// 使用公钥作为 HMAC 密钥伪造 token
+	forger, err := NewSignerWithKey(SignerConfig{}, pubPEM)
+	require.NoError(t, err)
+	token, err := forger.Sign(Claims{"sub": "admin"})
+	require.NoError(t, err)
+	_, err = verifier.Verify(token)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// alg none
+	none := b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"admin"}`)) + "."
+	_, err = verifier.Verify(none)
+	assert.ErrorIs(t, err, ErrAlgorithm)
+
+	_, err = verifier.Verify("not-a-token")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	_, err = NewVerifier(Config{})
+	assert.Error(t, err)
+	_, err = NewVerifier(Config{Secret: "s", Algorithms: []string{"HS512"}})
+	assert.Error(t, err)
+	_, err = NewSignerWithKey(SignerConfig{Algorithm: RS256}, []byte("secret"))
+	assert.Error(t, err)
+}
+
+func TestFileKeySet_Rotation(t *testing.T) {
+	key1, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	key2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, file, ecJWK("k1", &key1.PublicKey))
+	keySet, err := NewFileKeySet(file, time.Millisecond)
+	require.NoError(t, err)
+	verifier, err := NewVerifierWithKeys(keySet, []string{ES256}, Config{})
+	require.NoError(t, err)
+
+	signer1, err := NewSignerWithKey(SignerConfig{KeyID: "k1"}, key1)
+	require.NoError(t, err)
+	signer2, err := NewSignerWithKey(SignerConfig{KeyID: "k2"}, key2)
+	require.NoError(t, err)
+	token1, err := signer1.Sign(Claims{"sub": "u1"})
+	require.NoError(t, err)
+	token2, err := signer2.Sign(Claims{"sub": "u2"})
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(token1)
+	assert.NoError(t, err)
+	_, err = verifier.Verify(token2)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	// 加入新 key
+	writeJWKS(t, file, ecJWK("k1", &key1.PublicKey), ecJWK("k2", &key2.PublicKey))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(time.Second)))
+	time.Sleep(5 * time.Millisecond)
+	_, err = verifier.Verify(token2)
+	assert.NoError(t, err)
+
+	// 文件损坏时继续使用旧的 key
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0600))
+	require.NoError(t, os.Chtimes(file, time.Now(), time.Now().Add(2*time.Second)))
+	time.Sleep(5 * time.Millisecond)
+	_, err = verifier.Verify(token1)
+	assert.NoError(t, err)
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys, err := ParseJWKS([]byte(`{"keys":[
+		{"kty":"oct","kid":"a","k":"c2VjcmV0"},
+		{"kty":"oct","kid":"enc","use":"enc","k":"c2VjcmV0"}
+	]}`))
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, HS256, keys[0].Algorithm)
+	assert.Equal(t, []byte("secret"), keys[0].Key)
+
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"oct","alg":"RS256","k":"c2VjcmV0"}]}`))
+	assert.Error(t, err)
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-384","x":"AA","y":"AA"}]}`))
+	assert.Error(t, err)
+	_, err = ParseJWKS([]byte(`{"keys":[{"kty":"EC","crv":"P-256","x":"AQ","y":"AQ"}]}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,242 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultJWKSReloadInterval = time.Minute
+
+// Key 验证签名的 key。HS256 使用 []byte, RS256 使用 *rsa.PublicKey, ES256 使用 *ecdsa.PublicKey(P-256)
+type Key struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// KeySet 根据 kid 获取 key, kid 为空时返回所有 key。没有 ID 的 key 匹配所有 kid
+type KeySet interface {
+	Keys(kid string) []Key
+}
+
+// StaticKeySet 固定的 key 列表
+type StaticKeySet []Key
+
+func (s StaticKeySet) Keys(kid string) []Key {
+	if kid == "" {
+		return s
+	}
+	keys := make([]Key, 0, 1)
+	for _, k := range s {
+		if k.ID == kid || k.ID == "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// multiKeySet 按顺序合并多个 KeySet
+type multiKeySet []KeySet
+
+func (m multiKeySet) Keys(kid string) []Key {
+	var keys []Key
+	for _, s := range m {
+		keys = append(keys, s.Keys(kid)...)
+	}
+	return keys
+}
+
+// FileKeySet 从本地 JWKS 文件加载 key, 每隔 interval 检查一次文件的修改时间, 变化后重新加载。
+// 重新加载失败时继续使用旧的 key。轮换 key 时先把新 key 加入文件, 再使用新 kid 签发 token
+type FileKeySet struct {
+	file     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	keys      StaticKeySet
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// NewFileKeySet interval 小于等于 0 时使用 1m
+func NewFileKeySet(file string, interval time.Duration) (*FileKeySet, error) {
+	if interval <= 0 {
+		interval = defaultJWKSReloadInterval
+	}
+	s := &FileKeySet{file: file, interval: interval}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileKeySet) load() error {
+	info, err := os.Stat(s.file)
+	if err != nil {
+		return fmt.Errorf("stat jwks file: %w", err)
+	}
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return fmt.Errorf("read jwks file: %w", err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	s.keys = keys
+	s.modTime = info.ModTime()
+	s.lastCheck = time.Now()
+	return nil
+}
+
+func (s *FileKeySet) Keys(kid string) []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastCheck) >= s.interval {
+		s.lastCheck = time.Now()
+		info, err := os.Stat(s.file)
+		if err == nil && !info.ModTime().Equal(s.modTime) {
+			if err := s.load(); err != nil {
+				log.Printf("reload jwks file %s failed, keep using the previous keys. err: %v", s.file, err)
+			}
+		}
+	}
+	return s.keys.Keys(kid)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS 解析 JWKS({"keys": [...]}), 支持 oct, RSA, EC(P-256)。use 不是 sig 的 key 会被忽略
+func ParseJWKS(data []byte) (StaticKeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	keys := make(StaticKeySet, 0, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("parse jwks key #%d(kid: %s): %w", i, k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (k jwk) key() (Key, error) {
+	key := Key{ID: k.Kid, Algorithm: k.Alg}
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return key, fmt.Errorf("invalid oct key")
+		}
+		key.Key = secret
+		if key.Algorithm == "" {
+			key.Algorithm = HS256
+		}
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return key, fmt.Errorf("invalid rsa key")
+		}
+		key.Key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.Algorithm == "" {
+			key.Algorithm = RS256
+		}
+	case "EC":
+		if k.Crv != "P-256" {
+			return key, fmt.Errorf("unsupported ec curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return key, fmt.Errorf("invalid ec key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return key, fmt.Errorf("invalid ec key: point not on curve")
+		}
+		key.Key = pub
+		if key.Algorithm == "" {
+			key.Algorithm = ES256
+		}
+	default:
+		return key, fmt.Errorf("unsupported kty %q", k.Kty)
+	}
+	return key, checkKey(key.Algorithm, key.Key)
+}
+
+// ParsePublicKeyPEM 解析 PEM 格式的 RSA/EC 公钥或者证书
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found")
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
+
+// ParsePrivateKeyPEM 解析 PEM 格式的 RSA/EC 私钥
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+}
@@ -0,0 +1,131 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SignerConfig 签发 token 的配置
+type SignerConfig struct {
+	// Algorithm 为空时根据 key 判断: Secret 使用 HS256, PrivateKeyFile 根据私钥类型使用 RS256 或者 ES256
+	Algorithm string `mapstructure:"algorithm"`
+	Secret    string `mapstructure:"secret"`
+	// PrivateKeyFile PEM 格式的 RSA/EC(P-256) 私钥
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	// KeyID 写入 header 的 kid, 验证方根据 kid 选择 key
+	KeyID string `mapstructure:"key_id"`
+	// Issuer claims 中没有 iss 时使用
+	Issuer string `mapstructure:"issuer"`
+	// Audience claims 中没有 aud 时使用
+	Audience []string `mapstructure:"audience"`
+	// TTL claims 中没有 exp 时 exp = iat + TTL, 为 0 时不设置 exp
+	TTL time.Duration `mapstructure:"ttl"`
+}
+
+// Signer 签发 token
+type Signer struct {
+	alg      string
+	key      interface{}
+	kid      string
+	issuer   string
+	audience []string
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+// NewSigner 根据配置创建 Signer
+func NewSigner(conf SignerConfig) (*Signer, error) {
+	var key interface{}
+	switch {
+	case conf.Secret != "":
+		key = []byte(conf.Secret)
+	case conf.PrivateKeyFile != "":
+		data, err := os.ReadFile(conf.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwt private_key_file: %w", err)
+		}
+		if key, err = ParsePrivateKeyPEM(data); err != nil {
+			return nil, fmt.Errorf("parse jwt private_key_file: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("jwt: secret or private_key_file is required")
+	}
+	return NewSignerWithKey(conf, key)
+}
+
+// NewSignerWithKey key 为 []byte(HS256), *rsa.PrivateKey(RS256) 或者 *ecdsa.PrivateKey(ES256), 忽略 conf 中的 key 配置
+func NewSignerWithKey(conf SignerConfig, key interface{}) (*Signer, error) {
+	alg := conf.Algorithm
+	if alg == "" {
+		var err error
+		if alg, err = algorithmOf(key); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkKey(alg, key); err != nil {
+		return nil, err
+	}
+	return &Signer{
+		alg:      alg,
+		key:      key,
+		kid:      conf.KeyID,
+		issuer:   conf.Issuer,
+		audience: conf.Audience,
+		ttl:      conf.TTL,
+		now:      time.Now,
+	}, nil
+}
+
+// Sign 签发 token。claims 中没有 iat, jti, iss, aud, exp 时使用配置补充, 不会修改传入的 claims
+func (s *Signer) Sign(claims Claims) (string, error) {
+	now := s.now()
+	c := make(Claims, len(claims)+5)
+	for k, v := range claims {
+		c[k] = v
+	}
+	if _, ok := c["iat"]; !ok {
+		c["iat"] = now.Unix()
+	}
+	if _, ok := c["jti"]; !ok {
+		c["jti"] = newJTI()
+	}
+	if _, ok := c["iss"]; !ok && s.issuer != "" {
+		c["iss"] = s.issuer
+	}
+	if _, ok := c["aud"]; !ok && len(s.audience) > 0 {
+		if len(s.audience) == 1 {
+			c["aud"] = s.audience[0]
+		} else {
+			c["aud"] = s.audience
+		}
+	}
+	if _, ok := c["exp"]; !ok && s.ttl > 0 {
+		c["exp"] = now.Add(s.ttl).Unix()
+	}
+
+	h, err := json.Marshal(header{Alg: s.alg, Kid: s.kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("jwt: marshal claims: %w", err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := sign(s.alg, s.key, []byte(signed))
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func newJTI() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
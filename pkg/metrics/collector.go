@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// DefBuckets 默认的 histogram 分桶, 单位秒
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// value 支持并发的 float64
+type value struct {
+	bits uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&v.bits)
+		n := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&v.bits, old, n) {
+			return
+		}
+	}
+}
+
+func (v *value) set(f float64) {
+	atomic.StoreUint64(&v.bits, math.Float64bits(f))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&v.bits))
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	v value
+}
+
+func (c *Counter) Inc() {
+	c.v.add(1)
+}
+
+// Add delta 小于 0 时 panic
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease in value")
+	}
+	c.v.add(delta)
+}
+
+func (c *Counter) Value() float64 {
+	return c.v.get()
+}
+
+// Gauge 可增可减的数值
+type Gauge struct {
+	v value
+}
+
+func (g *Gauge) Set(f float64) {
+	g.v.set(f)
+}
+
+func (g *Gauge) Inc() {
+	g.v.add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.v.add(-1)
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.v.add(delta)
+}
+
+func (g *Gauge) Value() float64 {
+	return g.v.get()
+}
+
+// Histogram 按分桶统计观测值
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *Histogram) Observe(f float64) {
+	idx := sort.SearchFloat64s(h.buckets, f)
+	h.mu.Lock()
+	if idx < len(h.buckets) {
+		h.counts[idx]++
+	}
+	h.count++
+	h.sum += f
+	h.mu.Unlock()
+}
+
+// snapshot 返回累计的分桶计数
+func (h *Histogram) snapshot() (cumulative []uint64, count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cumulative = make([]uint64, len(h.counts))
+	var acc uint64
+	for i, c := range h.counts {
+		acc += c
+		cumulative[i] = acc
+	}
+	return cumulative, h.count, h.sum
+}
+
+// Count 观测次数
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+type CounterVec struct {
+	*vec[Counter]
+}
+
+type GaugeVec struct {
+	*vec[Gauge]
+}
+
+type HistogramVec struct {
+	*vec[Histogram]
+	buckets []float64
+}
+
+// NewCounterVec 在默认 Registry 中创建 counter, 同名同 label 的 counter 已经存在时直接返回
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return defaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGaugeVec 在默认 Registry 中创建 gauge
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return defaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewHistogramVec 在默认 Registry 中创建 histogram, buckets 为空时使用 DefBuckets
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return defaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	d := &desc{name: name, help: help, typ: typeCounter, labelNames: labelNames}
+	return r.register(d, func() collector {
+		return &CounterVec{vec: newVec(d, func() *Counter { return &Counter{} })}
+	}).(*CounterVec)
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	d := &desc{name: name, help: help, typ: typeGauge, labelNames: labelNames}
+	return r.register(d, func() collector {
+		return &GaugeVec{vec: newVec(d, func() *Gauge { return &Gauge{} })}
+	}).(*GaugeVec)
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	for _, label := range labelNames {
+		if label == "le" {
+			panic(fmt.Sprintf("metrics: histogram %s can not use label le", name))
+		}
+	}
+	d := &desc{name: name, help: help, typ: typeHistogram, labelNames: labelNames}
+	return r.register(d, func() collector {
+		return &HistogramVec{
+			vec:     newVec(d, func() *Histogram { return newHistogram(buckets) }),
+			buckets: buckets,
+		}
+	}).(*HistogramVec)
+}
+
+// WithLabelValues 按 labelNames 的顺序传入 label 值, 数量不一致时 panic
+func (v *CounterVec) WithLabelValues(labelValues ...string) *Counter {
+	return v.with(labelValues)
+}
+
+// DeleteLabelValues 删除 label 值对应的子指标
+func (v *CounterVec) DeleteLabelValues(labelValues ...string) bool {
+	return v.delete(labelValues)
+}
+
+func (v *CounterVec) write(w *bufio.Writer) {
+	v.each(func(labelValues []string, c *Counter) {
+		fmt.Fprintf(w, "%s%s %s\n", v.d.name, formatLabels(v.d.labelNames, labelValues, "", ""), formatFloat(c.Value()))
+	})
+}
+
+func (v *GaugeVec) WithLabelValues(labelValues ...string) *Gauge {
+	return v.with(labelValues)
+}
+
+func (v *GaugeVec) DeleteLabelValues(labelValues ...string) bool {
+	return v.delete(labelValues)
+}
+
+func (v *GaugeVec) write(w *bufio.Writer) {
+	v.each(func(labelValues []string, g *Gauge) {
+		fmt.Fprintf(w, "%s%s %s\n", v.d.name, formatLabels(v.d.labelNames, labelValues, "", ""), formatFloat(g.Value()))
+	})
+}
+
+func (v *HistogramVec) WithLabelValues(labelValues ...string) *Histogram {
+	return v.with(labelValues)
+}
+
+func (v *HistogramVec) DeleteLabelValues(labelValues ...string) bool {
+	return v.delete(labelValues)
+}
+
+func (v *HistogramVec) write(w *bufio.Writer) {
+	v.each(func(labelValues []string, h *Histogram) {
+		cumulative, count, sum := h.snapshot()
+		for i, upper := range v.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.d.name, formatLabels(v.d.labelNames, labelValues, "le", formatFloat(upper)), cumulative[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.d.name, formatLabels(v.d.labelNames, labelValues, "le", "+Inf"), count)
+		labels := formatLabels(v.d.labelNames, labelValues, "", "")
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.d.name, labels, formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.d.name, labels, count)
+	})
+}
@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+
+	// ContentType Prometheus text exposition format
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	labelSeparator = "\xff"
+)
+
+var (
+	defaultRegistry = NewRegistry()
+
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Registry 保存所有指标，按 Prometheus text 格式输出
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]collector
+}
+
+type collector interface {
+	desc() *desc
+	write(w *bufio.Writer)
+}
+
+type desc struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]collector)}
+}
+
+// Default 默认的 Registry, 包级别的 NewXxx 函数都注册到这里
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// register 同名指标重复注册时, 类型和 label 一致返回已经存在的指标, 否则 panic
+func (r *Registry) register(d *desc, create func() collector) collector {
+	if !metricNameRE.MatchString(d.name) {
+		panic(fmt.Sprintf("metrics: invalid metric name: %s", d.name))
+	}
+	for _, label := range d.labelNames {
+		if !labelNameRE.MatchString(label) || strings.HasPrefix(label, "__") {
+			panic(fmt.Sprintf("metrics: invalid label name. metric: %s, label: %s", d.name, label))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if exists, ok := r.metrics[d.name]; ok {
+		ed := exists.desc()
+		if ed.typ != d.typ || strings.Join(ed.labelNames, ",") != strings.Join(d.labelNames, ",") {
+			panic(fmt.Sprintf("metrics: metric duplicate with different type or labels. name: %s", d.name))
+		}
+		return exists
+	}
+	c := create()
+	r.metrics[d.name] = c
+	return c
+}
+
+// Unregister 删除指标
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.metrics, name)
+}
+
+// WriteText 按指标名排序输出 Prometheus text 格式
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	collectors := make([]collector, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		collectors = append(collectors, r.metrics[name])
+	}
+	r.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, c := range collectors {
+		d := c.desc()
+		if d.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", d.name, d.typ)
+		c.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler 输出 Registry 中所有指标的 http.Handler
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		if err := r.WriteText(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Handler 输出默认 Registry 中所有指标
+func Handler() http.Handler {
+	return defaultRegistry.Handler()
+}
+
+// vec 按 label 值保存子指标
+type vec[T any] struct {
+	d        *desc
+	mu       sync.RWMutex
+	children map[string]*child[T]
+	newChild func() *T
+}
+
+type child[T any] struct {
+	labelValues []string
+	metric      *T
+}
+
+func newVec[T any](d *desc, newChild func() *T) *vec[T] {
+	return &vec[T]{d: d, children: make(map[string]*child[T]), newChild: newChild}
+}
+
+func (v *vec[T]) desc() *desc {
+	return v.d
+}
+
+func (v *vec[T]) with(labelValues []string) *T {
+	if len(labelValues) != len(v.d.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.d.name, len(v.d.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, labelSeparator)
+
+	v.mu.RLock()
+	c, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return c.metric
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.children[key]; ok {
+		return c.metric
+	}
+	c = &child[T]{labelValues: append([]string(nil), labelValues...), metric: v.newChild()}
+	v.children[key] = c
+	return c.metric
+}
+
+func (v *vec[T]) delete(labelValues []string) bool {
+	key := strings.Join(labelValues, labelSeparator)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	_, ok := v.children[key]
+	delete(v.children, key)
+	return ok
+}
+
+// each 按 label 值排序遍历, 保证输出稳定
+func (v *vec[T]) each(fn func(labelValues []string, metric *T)) {
+	v.mu.RLock()
+	children := make([]*child[T], 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mu.RUnlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return strings.Join(children[i].labelValues, labelSeparator) < strings.Join(children[j].labelValues, labelSeparator)
+	})
+	for _, c := range children {
+		fn(c.labelValues, c.metric)
+	}
+}
+
+// formatLabels 输出 {a="1",b="2"}, extra 追加在最后, 例如 histogram 的 le
+func formatLabels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(values[i]))
+		sb.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(extraValue)
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
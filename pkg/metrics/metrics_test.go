@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("app_requests_total", "Total requests.\nSecond line", "method", "path")
+	requests.WithLabelValues("GET", "/users").Inc()
+	requests.WithLabelValues("GET", "/users").Add(2)
+	requests.WithLabelValues("POST", `/a"b\c`).Inc()
+
+	inFlight := r.NewGaugeVec("app_in_flight", "In flight requests.")
+	inFlight.WithLabelValues().Inc()
+	inFlight.WithLabelValues().Inc()
+	inFlight.WithLabelValues().Dec()
+
+	latency := r.NewHistogramVec("app_latency_seconds", "Latency.", []float64{1, 0.1}, "method")
+	latency.WithLabelValues("GET").Observe(0.05)
+	latency.WithLabelValues("GET").Observe(0.5)
+	latency.WithLabelValues("GET").Observe(5)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.WriteText(buf))
+
+	expected := `# HELP app_in_flight In flight requests.
+# TYPE app_in_flight gauge
+app_in_flight 1
+# HELP app_latency_seconds Latency.
+# TYPE app_latency_seconds histogram
+app_latency_seconds_bucket{method="GET",le="0.1"} 1
+app_latency_seconds_bucket{method="GET",le="1"} 2
+app_latency_seconds_bucket{method="GET",le="+Inf"} 3
+app_latency_seconds_sum{method="GET"} 5.55
+app_latency_seconds_count{method="GET"} 3
+# HELP app_requests_total Total requests.\nSecond line
+# TYPE app_requests_total counter
+app_requests_total{method="GET",path="/users"} 3
+app_requests_total{method="POST",path="/a\"b\\c"} 1
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestRegistry_Register(t *testing.T) {
+	r := NewRegistry()
+
+	c1 := r.NewCounterVec("jobs_total", "Jobs.", "queue")
+	c2 := r.NewCounterVec("jobs_total", "Jobs.", "queue")
+	assert.Same(t, c1, c2)
+
+	assert.Panics(t, func() { r.NewGaugeVec("jobs_total", "Jobs.", "queue") })
+	assert.Panics(t, func() { r.NewCounterVec("jobs_total", "Jobs.", "other") })
+	assert.Panics(t, func() { r.NewCounterVec("bad-name", "") })
+	assert.Panics(t, func() { r.NewCounterVec("ok_total", "", "__reserved") })
+	assert.Panics(t, func() { r.NewHistogramVec("h_seconds", "", nil, "le") })
+	assert.Panics(t, func() { c1.WithLabelValues("a", "b") })
+	assert.Panics(t, func() { c1.WithLabelValues("a").Add(-1) })
+
+	c1.WithLabelValues("a").Inc()
+	assert.True(t, c1.DeleteLabelValues("a"))
+	assert.False(t, c1.DeleteLabelValues("a"))
+
+	r.Unregister("jobs_total")
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.WriteText(buf))
+	assert.Empty(t, buf.String())
+}
+
+func TestCounter_Concurrent(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("concurrent_total", "", "worker")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.WithLabelValues("w").Inc()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, float64(10000), c.WithLabelValues("w").Value())
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("handler_total", "").WithLabelValues().Inc()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "# TYPE handler_total counter\nhandler_total 1\n", w.Body.String())
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	AccessLogFormatJSON     = "json"
+	AccessLogFormatLogfmt   = "logfmt"
+	AccessLogFormatCombined = "combined"
+
+	AccessLogOutputLogrus = "logrus"
+	AccessLogOutputStdout = "stdout"
+	AccessLogOutputStderr = "stderr"
+	AccessLogOutputFile   = "file"
+
+	combinedTimeLayout = "02/Jan/2006:15:04:05 -0700"
+)
+
+// AccessLog 访问日志, 每个请求输出一条结构化日志, 默认开启
+type AccessLog struct {
+	Disable bool `mapstructure:"disable"`
+	// Format json(默认), logfmt, combined(Apache combined 格式, 末尾追加 trace_id, route, retcode, latency)
+	Format string `mapstructure:"format"`
+	// Output logrus(默认, 与 logger.Config 配置的输出一致), stdout, stderr, file
+	Output string `mapstructure:"output"`
+	// File Output 为 file 时的日志文件配置
+	File AccessLogFile `mapstructure:"file"`
+	// SkipPaths 不记录访问日志的路径, 例如 /healthz
+	SkipPaths []string `mapstructure:"skip_paths"`
+}
+
+type AccessLogFile struct {
+	Filename   string `mapstructure:"filename"`
+	MaxFileMB  int    `mapstructure:"max_file_mb"`
+	MaxBackups int    `mapstructure:"max_backups"`
+	MaxAgeDays int    `mapstructure:"max_age_days"`
+	Compress   bool   `mapstructure:"compress"`
+}
+
+// AccessLogRecord 一条访问日志
+type AccessLogRecord struct {
+	Time      time.Time     `json:"time"`
+	TraceID   string        `json:"trace_id"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Route     string        `json:"route"`
+	Proto     string        `json:"proto"`
+	Status    int           `json:"status"`
+	Retcode   *int          `json:"retcode,omitempty"`
+	Latency   time.Duration `json:"-"`
+	Bytes     int           `json:"bytes"`
+	ClientIP  string        `json:"client_ip"`
+	UserAgent string        `json:"user_agent"`
+	Referer   string        `json:"referer"`
+}
+
+func (a AccessLog) validate() error {
+	switch a.Format {
+	case "", AccessLogFormatJSON, AccessLogFormatLogfmt, AccessLogFormatCombined:
+	default:
+		return fmt.Errorf("unsupported access log format %q", a.Format)
+	}
+	switch a.Output {
+	case "", AccessLogOutputLogrus, AccessLogOutputStdout, AccessLogOutputStderr:
+	case AccessLogOutputFile:
+		if a.File.Filename == "" {
+			return fmt.Errorf("access log file.filename is required")
+		}
+	default:
+		return fmt.Errorf("unsupported access log output %q", a.Output)
+	}
+	return nil
+}
+
+type accessLogger struct {
+	mu        sync.Mutex
+	format    string
+	out       func() io.Writer
+	closer    io.Closer // Output 为 file 时的日志文件, server 关闭时关闭
+	skipPaths map[string]struct{}
+}
+
+func newAccessLogger(conf AccessLog) (*accessLogger, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+	l := &accessLogger{format: conf.Format, skipPaths: make(map[string]struct{})}
+	if l.format == "" {
+		l.format = AccessLogFormatJSON
+	}
+	for _, path := range conf.SkipPaths {
+		l.skipPaths[path] = struct{}{}
+	}
+
+	switch conf.Output {
+	case AccessLogOutputStdout:
+		l.out = func() io.Writer { return os.Stdout }
+	case AccessLogOutputStderr:
+		l.out = func() io.Writer { return os.Stderr }
+	case AccessLogOutputFile:
+		file := &lumberjack.Logger{
+			Filename:   conf.File.Filename,
+			MaxSize:    conf.File.MaxFileMB,
+			MaxBackups: conf.File.MaxBackups,
+			MaxAge:     conf.File.MaxAgeDays,
+			Compress:   conf.File.Compress,
+		}
+		l.out = func() io.Writer { return file }
+		l.closer = file
+	default:
+		// 每次写入时获取, logger.Config 可能在中间件创建之后才修改输出
+		l.out = func() io.Writer { return logrus.StandardLogger().Out }
+	}
+	return l, nil
+}
+
+func (l *accessLogger) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := l.skipPaths[c.Request.URL.Path]; ok {
+			c.Next()
+			return
+		}
+
+		// 提前生成 trace id, coreContext.NewContext 会复用, 保证访问日志与业务日志的 trace id 一致
+		ctx := coreContext.AdjustCtxLogID(c.Request.Context())
+		c.Request = c.Request.WithContext(ctx)
+
+		start := time.Now()
+		c.Next()
+
+		record := AccessLogRecord{
+			Time:      start,
+			TraceID:   coreContext.GetLogID(ctx),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Route:     c.FullPath(),
+			Proto:     c.Request.Proto,
+			Status:    c.Writer.Status(),
+			Latency:   time.Since(start),
+			Bytes:     c.Writer.Size(),
+			ClientIP:  c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Referer:   c.Request.Referer(),
+		}
+		if record.Bytes < 0 {
+			record.Bytes = 0
+		}
+		if retcode, ok := middleware.GetRetcode(c); ok {
+			record.Retcode = &retcode
+		}
+		l.write(record)
+	}
+}
+
+// Close 关闭日志文件, 其他输出不需要关闭
+func (l *accessLogger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closer.Close()
+}
+
+func (l *accessLogger) write(record AccessLogRecord) {
+	line := formatAccessLog(l.format, record)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out().Write(line); err != nil {
+		logrus.Errorf("write access log failed: %v", err)
+	}
+}
+
+func formatAccessLog(format string, r AccessLogRecord) []byte {
+	buf := &bytes.Buffer{}
+	switch format {
+	case AccessLogFormatLogfmt:
+		writeLogfmt(buf, "time", r.Time.Format(time.RFC3339Nano))
+		writeLogfmt(buf, "trace_id", r.TraceID)
+		writeLogfmt(buf, "method", r.Method)
+		writeLogfmt(buf, "path", r.Path)
+		writeLogfmt(buf, "route", r.Route)
+		writeLogfmt(buf, "proto", r.Proto)
+		writeLogfmt(buf, "status", strconv.Itoa(r.Status))
+		if r.Retcode != nil {
+			writeLogfmt(buf, "retcode", strconv.Itoa(*r.Retcode))
+		}
+		writeLogfmt(buf, "latency_ms", formatLatencyMs(r.Latency))
+		writeLogfmt(buf, "bytes", strconv.Itoa(r.Bytes))
+		writeLogfmt(buf, "client_ip", r.ClientIP)
+		writeLogfmt(buf, "user_agent", r.UserAgent)
+		writeLogfmt(buf, "referer", r.Referer)
+	case AccessLogFormatCombined:
+		size := "-"
+		if r.Bytes > 0 {
+			size = strconv.Itoa(r.Bytes)
+		}
+		fmt.Fprintf(buf, `%s - - [%s] "%s %s %s" %d %s %s %s`,
+			r.ClientIP, r.Time.Format(combinedTimeLayout), r.Method, r.Path, r.Proto, r.Status, size,
+			strconv.Quote(orDash(r.Referer)), strconv.Quote(orDash(r.UserAgent)))
+		writeLogfmt(buf, "trace_id", r.TraceID)
+		writeLogfmt(buf, "route", r.Route)
+		if r.Retcode != nil {
+			writeLogfmt(buf, "retcode", strconv.Itoa(*r.Retcode))
+		}
+		writeLogfmt(buf, "latency_ms", formatLatencyMs(r.Latency))
+	default:
+		type jsonRecord struct {
+			AccessLogRecord
+			LatencyMs json.Number `json:"latency_ms"`
+		}
+		data, err := json.Marshal(jsonRecord{AccessLogRecord: r, LatencyMs: json.Number(formatLatencyMs(r.Latency))})
+		if err != nil {
+			// 所有字段都可以序列化, 不会出现
+			data = []byte(strconv.Quote(err.Error()))
+		}
+		buf.Write(data)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// writeLogfmt 写入 key=value, value 包含空格, 引号, = 或者为空时加引号
+func writeLogfmt(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(key)
+	buf.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " \"=\t\r\n\\") {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+func formatLatencyMs(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog_Middleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	l, err := newAccessLogger(AccessLog{SkipPaths: []string{"/healthz"}})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	l.out = func() io.Writer { return buf }
+
+	var handlerTraceID string
+	web := middleware.NewWeb("/access-log")
+	web.Route(web.Get("/users/:id").NoLogin().Handler(func(ctx coreContext.Contexts) errors.Error {
+		handlerTraceID = ctx.GetRequestID()
+		return errors.NewError(4004, "user not found")
+	}))
+	engine := gin.New()
+	engine.Use(l.middleware())
+	web.RegisterGinRoutes(engine)
+	engine.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/access-log/users/1", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	engine.ServeHTTP(w, req)
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	record := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+
+	assert.NotEmpty(t, handlerTraceID)
+	assert.Equal(t, handlerTraceID, record["trace_id"])
+	assert.Equal(t, w.Header().Get(middleware.RequestId), record["trace_id"])
+	assert.Equal(t, "/access-log/users/:id", record["route"])
+	assert.Equal(t, "/access-log/users/1", record["path"])
+	assert.Equal(t, float64(http.StatusOK), record["status"])
+	assert.Equal(t, float64(4004), record["retcode"])
+	assert.Equal(t, float64(w.Body.Len()), record["bytes"])
+	assert.Equal(t, "test-agent", record["user_agent"])
+	assert.Equal(t, "192.0.2.1", record["client_ip"])
+	assert.Contains(t, record, "latency_ms")
+}
+
+func TestFormatAccessLog(t *testing.T) {
+	retcode := 0
+	record := AccessLogRecord{
+		Time:      time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		TraceID:   "svc:1",
+		Method:    http.MethodPost,
+		Path:      "/api/users",
+		Route:     "/api/users",
+		Proto:     "HTTP/1.1",
+		Status:    http.StatusOK,
+		Retcode:   &retcode,
+		Latency:   1500 * time.Microsecond,
+		Bytes:     42,
+		ClientIP:  "10.0.0.1",
+		UserAgent: "curl/8.0 (x86_64)",
+	}
+
+	assert.Equal(t, `time=2024-05-01T08:30:00Z trace_id=svc:1 method=POST path=/api/users route=/api/users proto=HTTP/1.1 `+
+		`status=200 retcode=0 latency_ms=1.500 bytes=42 client_ip=10.0.0.1 user_agent="curl/8.0 (x86_64)" referer=""`+"\n",
+		string(formatAccessLog(AccessLogFormatLogfmt, record)))
+
+	assert.Equal(t, `10.0.0.1 - - [01/May/2024:08:30:00 +0000] "POST /api/users HTTP/1.1" 200 42 "-" "curl/8.0 (x86_64)" `+
+		`trace_id=svc:1 route=/api/users retcode=0 latency_ms=1.500`+"\n",
+		string(formatAccessLog(AccessLogFormatCombined, record)))
+
+	data := formatAccessLog(AccessLogFormatJSON, record)
+	assert.Contains(t, string(data), `"trace_id":"svc:1"`)
+	assert.Contains(t, string(data), `"latency_ms":1.500`)
+
+	// 非 Wrapper 处理的请求没有 retcode
+	record.Retcode = nil
+	assert.NotContains(t, string(formatAccessLog(AccessLogFormatJSON, record)), "retcode")
+	assert.NotContains(t, string(formatAccessLog(AccessLogFormatLogfmt, record)), "retcode")
+}
+
+func TestAccessLog_Validate(t *testing.T) {
+	assert.NoError(t, AccessLog{}.validate())
+	assert.NoError(t, AccessLog{Format: AccessLogFormatCombined, Output: AccessLogOutputStdout}.validate())
+	assert.Error(t, AccessLog{Format: "xml"}.validate())
+	assert.Error(t, AccessLog{Output: "kafka"}.validate())
+	assert.Error(t, AccessLog{Output: AccessLogOutputFile}.validate())
+
+	srv := NewHttpServer("test", Server{Port: "0", AccessLog: AccessLog{Format: "xml"}}, nil)
+	assert.Error(t, srv.Start(context.Background()))
+	assert.Nil(t, srv.Addr())
+}
+
+func TestHttpServer_AccessLogFileClosed(t *testing.T) {
+	if _, err := os.ReadDir("/proc/self/fd"); err != nil {
+		t.Skip("/proc/self/fd is not available")
+	}
+	// openFiles 当前进程打开 name 的 fd 个数
+	openFiles := func(name string) int {
+		fds, _ := os.ReadDir("/proc/self/fd")
+		n := 0
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name())); err == nil && target == name {
+				n++
+			}
+		}
+		return n
+	}
+
+	file := filepath.Join(t.TempDir(), "access.log")
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0", AccessLog: AccessLog{Output: AccessLogOutputFile, File: AccessLogFile{Filename: file}}})
+	for i := 0; i < 2; i++ {
+		require.NoError(t, srv.Start(context.Background()))
+		resp, err := http.Get("http://" + srv.Addr().String() + "/not-found")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, 1, openFiles(file))
+		require.NoError(t, srv.Stop(context.Background()))
+		// 每次关闭 server 时关闭日志文件
+		assert.Equal(t, 0, openFiles(file))
+	}
+	content, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(content), "/not-found"))
+}
@@ -0,0 +1,327 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	osErr "errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	runtimePprof "runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	"github.com/rentiansheng/go-api-component/pkg/config"
+	"github.com/rentiansheng/go-api-component/pkg/logger"
+	"github.com/rentiansheng/go-api-component/server/router"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAdminHost = "127.0.0.1"
+	redactedValue    = "******"
+)
+
+// Admin 运维端口, 只在单独的端口提供 pprof, 路由列表, 配置查看和日志级别调整, 不会暴露在业务端口
+type Admin struct {
+	Enable bool   `mapstructure:"enable"`
+	Port   string `mapstructure:"port"`
+	// Host 监听地址，默认 127.0.0.1, 只允许本机访问
+	Host string `mapstructure:"host"`
+	// RedactKeys 配置中包含这些关键字(不区分大小写)的 key 会被脱敏, 默认 password, secret, token, key, credential, private
+	RedactKeys []string `mapstructure:"redact_keys"`
+}
+
+var defaultRedactKeys = []string{"password", "passwd", "secret", "token", "key", "credential", "private"}
+
+type AdminRouteInfo struct {
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Handler       string `json:"handler"`
+	LoginRequired *bool  `json:"login_required,omitempty"`
+	// Authenticators 路由指定的认证方式
+	Authenticators []string `json:"authenticators,omitempty"`
+	// Permissions, Roles 路由要求的权限和角色
+	Permissions []string `json:"permissions,omitempty"`
+	Roles       []string `json:"roles,omitempty"`
+	CSRFExempt  bool     `json:"csrf_exempt,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+	Priority    string   `json:"priority,omitempty"`
+	Timeout     string   `json:"timeout,omitempty"`
+	MaxBodySize int64    `json:"max_body_size,omitempty"`
+	Consumes    []string `json:"consumes,omitempty"`
+	Produces    []string `json:"produces,omitempty"`
+}
+
+type adminServer struct {
+	srv  *http.Server
+	ln   net.Listener
+	done chan struct{}
+	err  error
+}
+
+func (h *httpServer) startAdmin(engine *gin.Engine) (*adminServer, error) {
+	conf := h.s.Admin
+	if conf.Port == "" {
+		return nil, fmt.Errorf("server %s admin port is required", h.name)
+	}
+	if conf.Port == h.s.Port && conf.Port != "0" {
+		return nil, fmt.Errorf("server %s admin port must be different from the public port", h.name)
+	}
+	host := conf.Host
+	if host == "" {
+		host = defaultAdminHost
+	}
+
+	addr := net.JoinHostPort(host, conf.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("server %s admin listen on %s: %w", h.name, addr, err)
+	}
+
+	a := &adminServer{
+		srv: &http.Server{
+			Handler:           h.adminHandler(engine),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		ln:   ln,
+		done: make(chan struct{}),
+	}
+	go func() {
+		a.err = a.srv.Serve(ln)
+		close(a.done)
+	}()
+	log.Printf("server %s admin listening on %s", h.name, ln.Addr())
+	return a, nil
+}
+
+func (a *adminServer) stop(ctx context.Context) error {
+	err := a.srv.Shutdown(ctx)
+	<-a.done
+	if err == nil && a.err != nil && !osErr.Is(a.err, http.ErrServerClosed) {
+		err = a.err
+	}
+	return err
+}
+
+func (h *httpServer) adminHandler(engine *gin.Engine) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/goroutines", adminGoroutines)
+
+	mux.HandleFunc("/admin/routes", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, middleware.OkResponse("", adminRoutes(engine, router.Get())))
+	})
+	mux.HandleFunc("/admin/config", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminJSON(w, http.StatusOK, middleware.OkResponse("", h.adminConfig()))
+	})
+	mux.HandleFunc("/admin/log/level", adminLogLevel)
+
+	return mux
+}
+
+// adminGoroutines 输出所有 goroutine 的完整调用栈
+func adminGoroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_ = runtimePprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+// adminRoutes 列出 gin 中实际注册的路由, Web 注册的路由会补充登录等信息
+func adminRoutes(engine *gin.Engine, routers []router.Router) []AdminRouteInfo {
+	type lister interface {
+		RouteInfos() []middleware.RouteInfo
+	}
+	webRoutes := make(map[string]middleware.RouteInfo)
+	for _, r := range routers {
+		if l, ok := r.(lister); ok {
+			for _, info := range l.RouteInfos() {
+				webRoutes[info.Method+" "+info.Path] = info
+			}
+		}
+	}
+
+	routes := make([]AdminRouteInfo, 0)
+	if engine == nil {
+		return routes
+	}
+	for _, r := range engine.Routes() {
+		info := AdminRouteInfo{Method: r.Method, Path: r.Path, Handler: r.Handler}
+		if webInfo, ok := webRoutes[r.Method+" "+r.Path]; ok {
+			loginRequired := webInfo.LoginRequired
+			info.LoginRequired = &loginRequired
+			info.Authenticators = webInfo.Authenticators
+			info.Permissions = webInfo.Permissions
+			info.Roles = webInfo.Roles
+			info.CSRFExempt = webInfo.CSRFExempt
+			info.Stream = webInfo.Stream
+			info.Priority = webInfo.Priority
+			info.Timeout = webInfo.Timeout
+			info.MaxBodySize = webInfo.MaxBodySize
+			info.Consumes = webInfo.Consumes
+			info.Produces = webInfo.Produces
+		}
+		routes = append(routes, info)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// adminConfig 当前生效的配置, 敏感字段脱敏
+func (h *httpServer) adminConfig() map[string]interface{} {
+	keys := h.s.Admin.RedactKeys
+	if len(keys) == 0 {
+		keys = defaultRedactKeys
+	}
+	return map[string]interface{}{
+		"server":   redact(structToMap(reflect.ValueOf(h.s)), keys),
+		"settings": redact(config.GetViper().AllSettings(), keys),
+	}
+}
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+// adminLogLevel GET 获取当前日志级别, PUT 修改日志级别。level 可以通过 query 或者 json body 传入
+func adminLogLevel(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body := logLevelBody{Level: r.URL.Query().Get("level")}
+		if body.Level == "" {
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				writeAdminJSON(w, http.StatusBadRequest, middleware.FailResponse(-1, "invalid body: "+err.Error(), nil))
+				return
+			}
+		}
+		level, err := logrus.ParseLevel(body.Level)
+		if err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, middleware.FailResponse(-1, err.Error(), nil))
+			return
+		}
+		logger.SetLevel(level)
+		log.Printf("log level changed to %s by admin api", level)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		writeAdminJSON(w, http.StatusMethodNotAllowed, middleware.FailResponse(-1, "method not allowed", nil))
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, middleware.OkResponse("", logLevelBody{Level: logrus.GetLevel().String()}))
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// structToMap 按 mapstructure tag 将配置结构体转换为 map
+func structToMap(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		result := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			result[name] = structToMap(v.Field(i))
+		}
+		return result
+	case reflect.Map:
+		result := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result[fmt.Sprint(iter.Key().Interface())] = structToMap(iter.Value())
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result[i] = structToMap(v.Index(i))
+		}
+		return result
+	}
+
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return v.Interface()
+}
+
+// redact 返回脱敏后的副本, key 包含敏感关键字且值不为空时替换为 ******
+func redact(v interface{}, keys []string) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			if isSensitiveKey(k, keys) && !isEmptyValue(item) {
+				result[k] = redactedValue
+				continue
+			}
+			result[k] = redact(item, keys)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, item := range val {
+			result[i] = redact(item, keys)
+		}
+		return result
+	default:
+		return v
+	}
+}
+
+func isSensitiveKey(key string, keys []string) bool {
+	key = strings.ToLower(key)
+	for _, k := range keys {
+		if strings.Contains(key, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.String:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	"github.com/rentiansheng/go-api-component/server/router"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startAdminTestServer(t *testing.T, s Server) (*httpServer, string, string) {
+	t.Helper()
+	srv := NewHttpServer("test", s, nil).(*httpServer)
+	require.NoError(t, srv.Start(context.Background()))
+	t.Cleanup(func() { _ = srv.Stop(context.Background()) })
+
+	srv.mu.Lock()
+	adminAddr := srv.admin.ln.Addr().String()
+	srv.mu.Unlock()
+	return srv, "http://" + srv.Addr().String(), "http://" + adminAddr
+}
+
+func getBody(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestHttpServer_Admin(t *testing.T) {
+	_, public, admin := startAdminTestServer(t, Server{
+		Port:   "0",
+		Health: Health{Enable: true},
+		Admin:  Admin{Enable: true, Port: "0"},
+		TLS:    TLS{KeyFile: "/etc/server.key"},
+	})
+
+	// 运维接口不会暴露在业务端口
+	for _, path := range []string{"/debug/pprof/", "/debug/goroutines", "/admin/routes", "/admin/config", "/admin/log/level"} {
+		status, _ := getBody(t, http.MethodGet, public+path, "")
+		assert.Equal(t, http.StatusNotFound, status, path)
+	}
+
+	status, body := getBody(t, http.MethodGet, admin+"/debug/pprof/", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "goroutine")
+
+	status, body = getBody(t, http.MethodGet, admin+"/debug/goroutines", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "goroutine ")
+
+	status, body = getBody(t, http.MethodGet, admin+"/admin/routes", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"path":"/healthz"`)
+
+	status, body = getBody(t, http.MethodGet, admin+"/admin/config", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"key_file":"******"`)
+	assert.NotContains(t, body, "/etc/server.key")
+}
+
+func TestHttpServer_AdminLogLevel(t *testing.T) {
+	origin := logrus.GetLevel()
+	defer logrus.SetLevel(origin)
+	logrus.SetLevel(logrus.InfoLevel)
+
+	_, _, admin := startAdminTestServer(t, Server{Port: "0", Admin: Admin{Enable: true, Port: "0"}})
+
+	status, body := getBody(t, http.MethodGet, admin+"/admin/log/level", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"level":"info"`)
+
+	status, body = getBody(t, http.MethodPut, admin+"/admin/log/level", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"level":"debug"`)
+	assert.Equal(t, logrus.DebugLevel, logrus.GetLevel())
+
+	status, _ = getBody(t, http.MethodPut, admin+"/admin/log/level?level=warn", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, logrus.WarnLevel, logrus.GetLevel())
+
+	status, _ = getBody(t, http.MethodPut, admin+"/admin/log/level", `{"level":"verbose"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = getBody(t, http.MethodDelete, admin+"/admin/log/level", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, status)
+}
+
+func TestHttpServer_AdminPortConflict(t *testing.T) {
+	srv := NewHttpServer("test", Server{Port: "18080", Admin: Admin{Enable: true, Port: "18080"}}, nil)
+	err := srv.Start(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, srv.Addr())
+}
+
+func TestAdminRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	web := middleware.NewWeb("/api")
+	web.Route(web.Get("/users").NoLogin())
+	web.Route(web.Post("/users"))
+	engine := gin.New()
+	web.RegisterGinRoutes(engine)
+	engine.GET("/raw", func(c *gin.Context) {})
+
+	routes := adminRoutes(engine, []router.Router{web})
+	require.Len(t, routes, 3)
+	assert.Equal(t, "/api/users", routes[0].Path)
+	assert.Equal(t, http.MethodGet, routes[0].Method)
+	assert.False(t, *routes[0].LoginRequired)
+	assert.True(t, *routes[1].LoginRequired)
+	assert.Equal(t, "/raw", routes[2].Path)
+	assert.Nil(t, routes[2].LoginRequired)
+}
+
+func TestStructToMapRedact(t *testing.T) {
+	conf := Server{
+		Port:            "8080",
+		ShutdownTimeout: 5 * time.Second,
+		Cors:            Cors{AllowedDomains: []string{"*"}},
+		TLS:             TLS{CertFile: "/etc/server.crt"},
+	}
+	m := redact(structToMap(reflect.ValueOf(conf)), defaultRedactKeys).(map[string]interface{})
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.Equal(t, "8080", m["port"])
+	assert.Equal(t, "5s", m["shutdown_timeout"])
+	assert.Contains(t, string(data), `"allowed_domains":["*"]`)
+	assert.Contains(t, string(data), `"cert_file":"/etc/server.crt"`)
+	// 空值不需要脱敏
+	assert.Contains(t, string(data), `"key_file":""`)
+
+	settings := redact(map[string]interface{}{
+		"db": map[string]interface{}{"user": "root", "Password": "123456"},
+		"list": []interface{}{
+			map[string]interface{}{"api_token": "abc"},
+		},
+	}, defaultRedactKeys)
+	assert.Equal(t, map[string]interface{}{
+		"db":   map[string]interface{}{"user": "root", "Password": redactedValue},
+		"list": []interface{}{map[string]interface{}{"api_token": redactedValue}},
+	}, settings)
+}
@@ -0,0 +1,24 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
+)
+
+// Concurrency 自适应并发限制, 根据请求延迟调整限制, 过载时按路由优先级返回 503
+type Concurrency struct {
+	Enable             bool `mapstructure:"enable"`
+	concurrency.Config `mapstructure:",squash"`
+}
+
+// newConcurrencyLimiter 开启时创建当前 server 的并发限制, 通过 engine 传给 middleware, 不修改全局设置
+func (h *httpServer) newConcurrencyLimiter() (*concurrency.Limiter, error) {
+	if !h.s.Concurrency.Enable {
+		return nil, nil
+	}
+	if err := h.s.Concurrency.Validate(); err != nil {
+		return nil, fmt.Errorf("server %s concurrency config: %w", h.name, err)
+	}
+	return concurrency.New(h.s.Concurrency.Config)
+}
@@ -0,0 +1,328 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	headerOrigin                    = "Origin"
+	headerVary                      = "Vary"
+	headerAllowOrigin               = "Access-Control-Allow-Origin"
+	headerAllowMethods              = "Access-Control-Allow-Methods"
+	headerAllowHeaders              = "Access-Control-Allow-Headers"
+	headerAllowCredentials          = "Access-Control-Allow-Credentials"
+	headerExposeHeaders             = "Access-Control-Expose-Headers"
+	headerMaxAge                    = "Access-Control-Max-Age"
+	headerAllowPrivateNetwork       = "Access-Control-Allow-Private-Network"
+	headerRequestMethod             = "Access-Control-Request-Method"
+	headerRequestHeaders            = "Access-Control-Request-Headers"
+	headerRequestPrivateNetwork     = "Access-Control-Request-Private-Network"
+	corsWildcard                    = "*"
+	corsWildcardSubdomain           = "*."
+	corsDefaultAllowedMethods       = "GET, HEAD, POST"
+	corsPreflightVary               = "Origin, Access-Control-Request-Method, Access-Control-Request-Headers"
+	corsPreflightPrivateNetworkVary = corsPreflightVary + ", Access-Control-Request-Private-Network"
+	corsPrivateNetworkRequested     = "true"
+)
+
+type Cors struct {
+	AllowedHeaders []string `mapstructure:"allowed_headers"` // * 允许所有请求头
+	AllowedMethods []string `mapstructure:"allowed_methods"` // 为空时允许 GET, HEAD, POST
+	// AllowedDomains 允许的 origin, 支持 *, 完整 origin(https://a.example.com) 和通配子域名(https://*.example.com)
+	AllowedDomains []string `mapstructure:"allowed_domains"`
+	// AllowedOriginPatterns 使用正则匹配 origin, 例如 ^https://[a-z0-9-]+\.example\.com$
+	AllowedOriginPatterns []string `mapstructure:"allowed_origin_patterns"`
+	// ExposedHeaders 允许浏览器读取的响应头
+	ExposedHeaders []string `mapstructure:"exposed_headers"`
+	// MaxAge 预检请求的缓存时间, 为 0 时不返回 Access-Control-Max-Age
+	MaxAge time.Duration `mapstructure:"max_age"`
+	// CookiesAllowed 允许携带 cookies。AllowedDomains 为 * 时不会返回 Access-Control-Allow-Credentials
+	CookiesAllowed bool `mapstructure:"cookies_allowed"`
+	// AllowPrivateNetwork 允许公网页面访问内网服务(Private Network Access)
+	AllowPrivateNetwork bool `mapstructure:"allow_private_network"`
+	EnableCORS          bool `mapstructure:"enable_cors"`
+	// Roots 按 Web root 覆盖全局配置, key 为路径前缀, 最长前缀优先。覆盖时使用完整的配置, 不与全局配置合并
+	Roots map[string]Cors `mapstructure:"roots"`
+}
+
+// enabled 全局配置或者任意 root 开启了 CORS
+func (c Cors) enabled() bool {
+	if c.EnableCORS {
+		return true
+	}
+	for _, rc := range c.Roots {
+		if rc.EnableCORS {
+			return true
+		}
+	}
+	return false
+}
+
+type corsPolicy struct {
+	enable          bool
+	allowAllOrigins bool
+	origins         map[string]struct{}
+	wildcardOrigins []wildcardOrigin
+	patterns        []*regexp.Regexp
+	allowedMethods  map[string]struct{}
+	methods         string
+	allowAllHeaders bool
+	allowedHeaders  map[string]struct{}
+	headers         string
+	exposedHeaders  string
+	maxAge          string
+	credentials     bool
+	privateNetwork  bool
+}
+
+// wildcardOrigin https://*.example.com 匹配 https://a.example.com, 不匹配 https://example.com
+type wildcardOrigin struct {
+	scheme string
+	suffix string
+}
+
+func (w wildcardOrigin) match(scheme, host string) bool {
+	return scheme == w.scheme && len(host) > len(w.suffix) && strings.HasSuffix(host, w.suffix)
+}
+
+type rootCorsPolicy struct {
+	root   string
+	policy *corsPolicy
+}
+
+// corsHandler 根据请求路径选择配置, Roots 中没有匹配的前缀时使用全局配置
+type corsHandler struct {
+	global *corsPolicy
+	roots  []rootCorsPolicy
+}
+
+func newCorsHandler(c Cors) (*corsHandler, error) {
+	global, err := compileCorsPolicy(c)
+	if err != nil {
+		return nil, err
+	}
+	h := &corsHandler{global: global}
+	for root, rc := range c.Roots {
+		policy, err := compileCorsPolicy(rc)
+		if err != nil {
+			return nil, fmt.Errorf("cors root %s: %w", root, err)
+		}
+		h.roots = append(h.roots, rootCorsPolicy{root: "/" + strings.Trim(root, "/"), policy: policy})
+	}
+	sort.Slice(h.roots, func(i, j int) bool {
+		return len(h.roots[i].root) > len(h.roots[j].root)
+	})
+	return h, nil
+}
+
+func (h *corsHandler) policy(path string) *corsPolicy {
+	for _, r := range h.roots {
+		if r.root == "/" || path == r.root || strings.HasPrefix(path, r.root+"/") {
+			return r.policy
+		}
+	}
+	return h.global
+}
+
+func compileCorsPolicy(c Cors) (*corsPolicy, error) {
+	p := &corsPolicy{
+		enable:         c.EnableCORS,
+		origins:        make(map[string]struct{}),
+		allowedMethods: make(map[string]struct{}),
+		allowedHeaders: make(map[string]struct{}),
+		credentials:    c.CookiesAllowed,
+		privateNetwork: c.AllowPrivateNetwork,
+	}
+
+	for _, domain := range c.AllowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		switch {
+		case domain == corsWildcard:
+			p.allowAllOrigins = true
+		case strings.Contains(domain, "://"+corsWildcardSubdomain):
+			scheme, host, _ := strings.Cut(domain, "://")
+			p.wildcardOrigins = append(p.wildcardOrigins, wildcardOrigin{scheme: scheme, suffix: host[1:]})
+		case domain != "":
+			p.origins[strings.TrimSuffix(domain, "/")] = struct{}{}
+		}
+	}
+	for _, pattern := range c.AllowedOriginPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cors allowed_origin_patterns %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+
+	methods := make([]string, 0, len(c.AllowedMethods))
+	for _, method := range c.AllowedMethods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		p.allowedMethods[method] = struct{}{}
+		methods = append(methods, method)
+	}
+	p.methods = strings.Join(methods, ", ")
+	if len(methods) == 0 {
+		p.methods = corsDefaultAllowedMethods
+		for _, method := range strings.Split(corsDefaultAllowedMethods, ", ") {
+			p.allowedMethods[method] = struct{}{}
+		}
+	}
+
+	headers := make([]string, 0, len(c.AllowedHeaders))
+	for _, header := range c.AllowedHeaders {
+		header = strings.TrimSpace(header)
+		if header == corsWildcard {
+			p.allowAllHeaders = true
+			continue
+		}
+		p.allowedHeaders[strings.ToLower(header)] = struct{}{}
+		headers = append(headers, header)
+	}
+	p.headers = strings.Join(headers, ", ")
+	p.exposedHeaders = strings.Join(c.ExposedHeaders, ", ")
+	if c.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(c.MaxAge / time.Second))
+	}
+	return p, nil
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.allowAllOrigins {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if _, ok := p.origins[lower]; ok {
+		return true
+	}
+	if len(p.wildcardOrigins) > 0 {
+		if u, err := url.Parse(lower); err == nil && u.Host != "" {
+			for _, w := range p.wildcardOrigins {
+				if w.match(u.Scheme, u.Host) {
+					return true
+				}
+			}
+		}
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setAllowOrigin 配置了 * 时返回 *, 此时不允许携带 cookies, 避免任意 origin 携带凭证访问
+func (p *corsPolicy) setAllowOrigin(c *gin.Context, origin string) {
+	if p.allowAllOrigins {
+		c.Header(headerAllowOrigin, corsWildcard)
+		return
+	}
+	c.Header(headerAllowOrigin, origin)
+	if p.credentials {
+		c.Header(headerAllowCredentials, "true")
+	}
+}
+
+func (p *corsPolicy) allowRequestHeaders(requested string) bool {
+	if p.allowAllHeaders {
+		return true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.ToLower(strings.TrimSpace(header))
+		if header == "" {
+			continue
+		}
+		if _, ok := p.allowedHeaders[header]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *corsPolicy) handlePreflight(c *gin.Context, origin string) {
+	requestMethod := strings.ToUpper(c.Request.Header.Get(headerRequestMethod))
+	requestHeaders := c.Request.Header.Get(headerRequestHeaders)
+	if _, ok := p.allowedMethods[requestMethod]; !ok || !p.allowRequestHeaders(requestHeaders) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	p.setAllowOrigin(c, origin)
+	c.Header(headerAllowMethods, p.methods)
+	if p.allowAllHeaders {
+		if requestHeaders != "" {
+			c.Header(headerAllowHeaders, requestHeaders)
+		}
+	} else if p.headers != "" {
+		c.Header(headerAllowHeaders, p.headers)
+	}
+	if p.maxAge != "" {
+		c.Header(headerMaxAge, p.maxAge)
+	}
+	if p.privateNetwork && c.Request.Header.Get(headerRequestPrivateNetwork) == corsPrivateNetworkRequested {
+		c.Header(headerAllowPrivateNetwork, "true")
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func (h *httpServer) corsMiddleware() gin.HandlerFunc {
+	handler, err := newCorsHandler(h.s.Cors)
+	if err != nil {
+		// Start 中已经校验过配置
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		p := handler.policy(c.Request.URL.Path)
+		if !p.enable {
+			c.Next()
+			return
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get(headerRequestMethod) != ""
+		if preflight {
+			vary := corsPreflightVary
+			if p.privateNetwork {
+				vary = corsPreflightPrivateNetworkVary
+			}
+			c.Writer.Header().Add(headerVary, vary)
+		} else {
+			c.Writer.Header().Add(headerVary, headerOrigin)
+		}
+
+		origin := c.Request.Header.Get(headerOrigin)
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !p.allowOrigin(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			// 非预检请求正常处理, 不返回 CORS 头, 由浏览器拦截
+			c.Next()
+			return
+		}
+
+		if preflight {
+			p.handlePreflight(c, origin)
+			return
+		}
+
+		p.setAllowOrigin(c, origin)
+		if p.exposedHeaders != "" {
+			c.Header(headerExposeHeaders, p.exposedHeaders)
+		}
+		c.Next()
+	}
+}
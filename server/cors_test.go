@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCorsTestEngine(conf Cors) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	srv := &httpServer{name: "test", s: Server{Cors: conf}}
+	engine := gin.New()
+	engine.Use(srv.corsMiddleware())
+	handler := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	engine.GET("/api/users", handler)
+	engine.OPTIONS("/api/users", handler)
+	engine.GET("/open/users", handler)
+	return engine
+}
+
+func doCorsRequest(engine *gin.Engine, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestCorsMiddleware_Origins(t *testing.T) {
+	engine := newCorsTestEngine(Cors{
+		EnableCORS:            true,
+		AllowedDomains:        []string{"https://app.example.com", "https://*.example.org"},
+		AllowedOriginPatterns: []string{`^https://[a-z]+\.example\.net$`},
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"http://app.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"http://a.example.org", false},
+		{"https://evil-example.org", false},
+		{"https://abc.example.net", true},
+		{"https://a1.example.net", false},
+	}
+	for _, tt := range tests {
+		w := doCorsRequest(engine, http.MethodGet, "/api/users", map[string]string{"Origin": tt.origin})
+		assert.Equal(t, http.StatusOK, w.Code, tt.origin)
+		assert.Equal(t, "Origin", w.Header().Get("Vary"), tt.origin)
+		if tt.allowed {
+			assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"), tt.origin)
+		} else {
+			assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), tt.origin)
+		}
+	}
+}
+
+func TestCorsMiddleware_Preflight(t *testing.T) {
+	engine := newCorsTestEngine(Cors{
+		EnableCORS:          true,
+		AllowedDomains:      []string{"https://app.example.com"},
+		AllowedMethods:      []string{"GET", "PUT"},
+		AllowedHeaders:      []string{"Content-Type", "X-Token"},
+		ExposedHeaders:      []string{"X-Trace-Id"},
+		MaxAge:              10 * time.Minute,
+		CookiesAllowed:      true,
+		AllowPrivateNetwork: true,
+	})
+
+	w := doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{
+		"Origin":                                 "https://app.example.com",
+		"Access-Control-Request-Method":          "PUT",
+		"Access-Control-Request-Headers":         "content-type, x-token",
+		"Access-Control-Request-Private-Network": "true",
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Private-Network"))
+	assert.Contains(t, w.Header().Get("Vary"), "Access-Control-Request-Headers")
+	// Expose-Headers 只在实际请求中返回
+	assert.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+
+	// 不允许的 method 或 header
+	w = doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{
+		"Origin":                        "https://app.example.com",
+		"Access-Control-Request-Method": "DELETE",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{
+		"Origin":                         "https://app.example.com",
+		"Access-Control-Request-Method":  "GET",
+		"Access-Control-Request-Headers": "X-Other",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 不允许的 origin
+	w = doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{
+		"Origin":                        "https://evil.com",
+		"Access-Control-Request-Method": "GET",
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// 非预检的 OPTIONS 请求交给业务处理
+	w = doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{"Origin": "https://app.example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+
+	// 实际请求
+	w = doCorsRequest(engine, http.MethodGet, "/api/users", map[string]string{"Origin": "https://app.example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "X-Trace-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+}
+
+func TestCorsMiddleware_WildcardWithCredentials(t *testing.T) {
+	engine := newCorsTestEngine(Cors{
+		EnableCORS:     true,
+		AllowedDomains: []string{"*"},
+		AllowedHeaders: []string{"*"},
+		CookiesAllowed: true,
+	})
+
+	w := doCorsRequest(engine, http.MethodGet, "/api/users", map[string]string{"Origin": "https://any.com"})
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = doCorsRequest(engine, http.MethodOptions, "/api/users", map[string]string{
+		"Origin":                         "https://any.com",
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "X-Anything",
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, HEAD, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "X-Anything", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func TestCorsMiddleware_Roots(t *testing.T) {
+	engine := newCorsTestEngine(Cors{
+		AllowedDomains: []string{"https://app.example.com"},
+		Roots: map[string]Cors{
+			"/open/": {EnableCORS: true, AllowedDomains: []string{"*"}},
+		},
+	})
+
+	// 全局未开启 CORS
+	w := doCorsRequest(engine, http.MethodGet, "/api/users", map[string]string{"Origin": "https://app.example.com"})
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+
+	w = doCorsRequest(engine, http.MethodGet, "/open/users", map[string]string{"Origin": "https://any.com"})
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// 前缀按路径段匹配
+	handler, err := newCorsHandler(Cors{Roots: map[string]Cors{"/open": {}, "/open/v2": {MaxAge: time.Second}}})
+	assert.NoError(t, err)
+	assert.Same(t, handler.global, handler.policy("/opened"))
+	assert.Equal(t, "1", handler.policy("/open/v2/users").maxAge)
+	assert.Equal(t, "", handler.policy("/open/v1").maxAge)
+	assert.NotSame(t, handler.global, handler.policy("/open"))
+}
+
+func TestHttpServer_StartInvalidCors(t *testing.T) {
+	srv := NewHttpServer("test", Server{
+		Port: "0",
+		Cors: Cors{EnableCORS: true, AllowedOriginPatterns: []string{"("}},
+	}, nil)
+	err := srv.Start(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, srv.Addr())
+}
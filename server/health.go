@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	"github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+)
+
+const (
+	defaultHealthPath         = "/healthz"
+	defaultLivenessPath       = "/livez"
+	defaultReadinessPath      = "/readyz"
+	defaultHealthCheckTimeout = 3 * time.Second
+
+	HealthStatusUp       = "up"
+	HealthStatusDegraded = "degraded"
+	HealthStatusDown     = "down"
+)
+
+type Health struct {
+	Enable        bool   `mapstructure:"enable"`
+	HealthPath    string `mapstructure:"health_path"`    // 默认 /healthz, 执行所有检查
+	LivenessPath  string `mapstructure:"liveness_path"`  // 默认 /livez, 进程存活即返回成功
+	ReadinessPath string `mapstructure:"readiness_path"` // 默认 /readyz, 服务 ready 且关键检查通过
+	// CheckTimeout 检查没有设置超时时间时使用, 默认 3s
+	CheckTimeout time.Duration `mapstructure:"check_timeout"`
+	// ShutdownDelay 关闭时 readiness 置为 false 之后等待的时间, 给负载均衡摘除流量留出时间
+	ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+}
+
+// CheckFunc 返回 nil 表示检查通过
+type CheckFunc func(ctx context.Context) error
+
+type HealthCheck struct {
+	Name  string
+	Check CheckFunc
+	// Timeout 为 0 时使用 Health.CheckTimeout
+	Timeout time.Duration
+	// Critical 关键检查失败时 readiness 失败，非关键检查失败时只将状态标记为 degraded
+	Critical bool
+}
+
+type HealthCheckResult struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string              `json:"status"`
+	Checks []HealthCheckResult `json:"checks"`
+}
+
+type HealthChecker interface {
+	// Register 注册检查, name 重复时 panic
+	Register(check HealthCheck)
+	// Check 并发执行所有检查
+	Check(ctx context.Context) HealthReport
+}
+
+type healthChecker struct {
+	mu             sync.RWMutex
+	checks         []HealthCheck
+	defaultTimeout time.Duration
+}
+
+func NewHealthChecker(defaultTimeout time.Duration) HealthChecker {
+	if defaultTimeout <= 0 {
+		defaultTimeout = defaultHealthCheckTimeout
+	}
+	return &healthChecker{defaultTimeout: defaultTimeout}
+}
+
+func (hc *healthChecker) Register(check HealthCheck) {
+	if check.Check == nil {
+		return
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	for _, c := range hc.checks {
+		if c.Name == check.Name {
+			panic(fmt.Sprintf("health check duplicate. name: %s", check.Name))
+		}
+	}
+	hc.checks = append(hc.checks, check)
+}
+
+func (hc *healthChecker) Check(ctx context.Context) HealthReport {
+	hc.mu.RLock()
+	checks := append([]HealthCheck(nil), hc.checks...)
+	hc.mu.RUnlock()
+
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for idx, check := range checks {
+		wg.Add(1)
+		go func(idx int, check HealthCheck) {
+			defer wg.Done()
+			results[idx] = hc.run(ctx, check)
+		}(idx, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthStatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status == HealthStatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = HealthStatusDown
+			break
+		}
+		report.Status = HealthStatusDegraded
+	}
+	return report
+}
+
+// run 超时后直接返回, 不等待检查函数结束
+func (hc *healthChecker) run(ctx context.Context, check HealthCheck) HealthCheckResult {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = hc.defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errCh <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+		errCh <- check.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = fmt.Errorf("timeout after %s", timeout)
+	}
+
+	result := HealthCheckResult{
+		Name:       check.Name,
+		Status:     HealthStatusUp,
+		Critical:   check.Critical,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Health 获取健康检查注册器
+func (h *httpServer) Health() HealthChecker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.health == nil {
+		h.health = NewHealthChecker(h.s.Health.CheckTimeout)
+	}
+	return h.health
+}
+
+// registerHealthRoutes 跳过业务已经注册的 GET 路径, 避免 gin 因为重复路由 panic
+func (h *httpServer) registerHealthRoutes(engine *gin.Engine) {
+	conf := h.s.Health
+	routes := []struct {
+		path    string
+		handler gin.HandlerFunc
+	}{
+		{pathOrDefault(conf.LivenessPath, defaultLivenessPath), h.livenessHandler},
+		{pathOrDefault(conf.ReadinessPath, defaultReadinessPath), h.readinessHandler},
+		{pathOrDefault(conf.HealthPath, defaultHealthPath), h.healthHandler},
+	}
+	for _, route := range routes {
+		if hasGETRoute(engine, route.path) {
+			log.Printf("Skip health route %s: already registered", route.path)
+			continue
+		}
+		engine.GET(route.path, route.handler)
+	}
+}
+
+// hasGETRoute engine 中是否已经注册了 path 的 GET 路由
+func hasGETRoute(engine *gin.Engine, path string) bool {
+	for _, route := range engine.Routes() {
+		if route.Method == http.MethodGet && route.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *httpServer) livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.OkResponse("", HealthReport{Status: HealthStatusUp, Checks: []HealthCheckResult{}}))
+}
+
+func (h *httpServer) readinessHandler(c *gin.Context) {
+	if !h.IsReady() {
+		err := errors.New(nil, code.ServerNotReadyErrCode)
+		c.JSON(http.StatusServiceUnavailable, middleware.FailResponse(int(err.Code()), err.Message(),
+			HealthReport{Status: HealthStatusDown, Checks: []HealthCheckResult{}}))
+		return
+	}
+	h.healthHandler(c)
+}
+
+func (h *httpServer) healthHandler(c *gin.Context) {
+	report := h.Health().Check(c.Request.Context())
+	if report.Checks == nil {
+		report.Checks = []HealthCheckResult{}
+	}
+	if report.Status == HealthStatusDown {
+		failed := make([]string, 0)
+		for _, result := range report.Checks {
+			if result.Status != HealthStatusUp && result.Critical {
+				failed = append(failed, result.Name)
+			}
+		}
+		sort.Strings(failed)
+		err := errors.New(nil, code.HealthCheckFailErrCode, strings.Join(failed, ","))
+		c.JSON(http.StatusServiceUnavailable, middleware.FailResponse(int(err.Code()), err.Message(), report))
+		return
+	}
+	c.JSON(http.StatusOK, middleware.OkResponse("", report))
+}
+
+func pathOrDefault(path, defaultPath string) string {
+	if path == "" {
+		return defaultPath
+	}
+	return path
+}
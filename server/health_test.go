@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware/errors/code"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type healthResponse struct {
+	Retcode int          `json:"retcode"`
+	Message string       `json:"message"`
+	Data    HealthReport `json:"data"`
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	hc := NewHealthChecker(50 * time.Millisecond)
+	hc.Register(HealthCheck{Name: "db", Critical: true, Check: func(ctx context.Context) error { return nil }})
+	hc.Register(HealthCheck{Name: "cache", Check: func(ctx context.Context) error { return errors.New("connection refused") }})
+	hc.Register(HealthCheck{Name: "nil-check"})
+
+	report := hc.Check(context.Background())
+	assert.Equal(t, HealthStatusDegraded, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, HealthStatusUp, report.Checks[0].Status)
+	assert.Equal(t, HealthStatusDown, report.Checks[1].Status)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+
+	hc.Register(HealthCheck{Name: "slow", Critical: true, Check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+	start := time.Now()
+	report = hc.Check(context.Background())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, HealthStatusDown, report.Status)
+	assert.Contains(t, report.Checks[2].Error, "timeout")
+
+	assert.Panics(t, func() {
+		hc.Register(HealthCheck{Name: "db", Check: func(ctx context.Context) error { return nil }})
+	})
+}
+
+func TestHealthChecker_CheckPanic(t *testing.T) {
+	hc := NewHealthChecker(0)
+	hc.Register(HealthCheck{Name: "panic", Critical: true, Check: func(ctx context.Context) error { panic("oops") }})
+
+	report := hc.Check(context.Background())
+	assert.Equal(t, HealthStatusDown, report.Status)
+	assert.Contains(t, report.Checks[0].Error, "oops")
+}
+
+func TestHttpServer_HealthRoutesRegistered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := New("test").(*httpServer)
+	engine := gin.New()
+	// 业务已经定义的路径保留业务的 handler
+	engine.GET("/healthz", func(c *gin.Context) { c.String(http.StatusOK, "custom") })
+	assert.NotPanics(t, func() { srv.registerHealthRoutes(engine) })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, "custom", w.Body.String())
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"retcode":0`)
+}
+
+func TestHttpServer_HealthRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := New("test").(*httpServer)
+	dbErr := error(nil)
+	srv.Health().Register(HealthCheck{Name: "db", Critical: true, Check: func(ctx context.Context) error { return dbErr }})
+	engine := srv.initRoutes()
+
+	do := func(path string) (int, healthResponse) {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp healthResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return w.Code, resp
+	}
+
+	status, resp := do("/livez")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, resp.Retcode)
+
+	// 未启动时 readiness 失败
+	status, resp = do("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, int(code.ServerNotReadyErrCode), resp.Retcode)
+
+	srv.ready.Store(true)
+	status, resp = do("/readyz")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, HealthStatusUp, resp.Data.Status)
+
+	dbErr = errors.New("down")
+	status, resp = do("/healthz")
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, int(code.HealthCheckFailErrCode), resp.Retcode)
+	assert.Equal(t, "health check failed. checks: db", resp.Message)
+	assert.Equal(t, HealthStatusDown, resp.Data.Status)
+}
+
+func TestHttpServer_HealthDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := NewHttpServer("test", Server{}, nil).(*httpServer)
+	engine := srv.initRoutes()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHttpServer_ReadinessOnShutdown(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{
+		Port:   "0",
+		Health: Health{Enable: true, ShutdownDelay: 100 * time.Millisecond},
+	})
+	require.NoError(t, srv.Start(context.Background()))
+	url := "http://" + srv.Addr().String() + "/readyz"
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- srv.Stop(context.Background()) }()
+
+	// ShutdownDelay 期间仍然接收请求, 但是 readiness 失败
+	assert.Eventually(t, func() bool { return !srv.IsReady() }, time.Second, 5*time.Millisecond)
+	resp, err = http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	assert.NoError(t, <-stopped)
+}
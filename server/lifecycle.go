@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultShutdownTimeout = 5 * time.Second
+
+// Hook 服务生命周期回调
+type Hook func(ctx context.Context) error
+
+// hooks 按注册顺序执行
+type hooks struct {
+	onStart    []Hook
+	onReady    []Hook
+	onShutdown []Hook
+	onStopped  []Hook
+}
+
+// OnStart 在监听端口之前执行, 返回错误时服务不会启动
+func (h *httpServer) OnStart(fns ...Hook) {
+	h.hooks.onStart = append(h.hooks.onStart, fns...)
+}
+
+// OnReady 在端口监听成功，开始接收请求后执行
+func (h *httpServer) OnReady(fns ...Hook) {
+	h.hooks.onReady = append(h.hooks.onReady, fns...)
+}
+
+// OnShutdown 在 readiness 置为 false 之后, 排空请求之前执行
+func (h *httpServer) OnShutdown(fns ...Hook) {
+	h.hooks.onShutdown = append(h.hooks.onShutdown, fns...)
+}
+
+// OnStopped 在所有请求处理完成, 服务停止后执行
+func (h *httpServer) OnStopped(fns ...Hook) {
+	h.hooks.onStopped = append(h.hooks.onStopped, fns...)
+}
+
+// IsReady 服务是否可以接收流量
+func (h *httpServer) IsReady() bool {
+	return h.ready.Load()
+}
+
+func (h *httpServer) shutdownTimeout() time.Duration {
+	if h.s.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return h.s.ShutdownTimeout
+}
+
+// runHooks 依次执行, 遇到错误立即返回
+func runHooks(ctx context.Context, stage string, fns []Hook) error {
+	for idx, fn := range fns {
+		if fn == nil {
+			continue
+		}
+		if err := fn(ctx); err != nil {
+			return fmt.Errorf("%s hook #%d failed: %w", stage, idx, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rentiansheng/go-api-component/middleware"
+	coreContext "github.com/rentiansheng/go-api-component/middleware/context"
+	apiErrors "github.com/rentiansheng/go-api-component/middleware/errors"
+	"github.com/rentiansheng/go-api-component/server/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHooks(t *testing.T) {
+	var calls []int
+	err := runHooks(context.Background(), "start", []Hook{
+		func(ctx context.Context) error { calls = append(calls, 1); return nil },
+		nil,
+		func(ctx context.Context) error { calls = append(calls, 2); return errors.New("boom") },
+		func(ctx context.Context) error { calls = append(calls, 3); return nil },
+	})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "start hook #2 failed: boom")
+	assert.Equal(t, []int{1, 2}, calls)
+}
+
+func TestHttpServer_ShutdownTimeout(t *testing.T) {
+	srv := New("test").(*httpServer)
+	assert.Equal(t, defaultShutdownTimeout, srv.shutdownTimeout())
+
+	srv.SetServerConfig(Server{ShutdownTimeout: 30 * time.Second})
+	assert.Equal(t, 30*time.Second, srv.shutdownTimeout())
+
+	srv.SetServerConfig(Server{})
+	assert.Equal(t, defaultShutdownTimeout, srv.shutdownTimeout())
+}
+
+func TestHttpServer_RunStartHookError(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0"})
+	srv.OnStart(func(ctx context.Context) error {
+		return errors.New("dependency not ready")
+	})
+
+	err := srv.Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "dependency not ready")
+	assert.False(t, srv.IsReady())
+}
+
+func TestHttpServer_RunListenError(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: port})
+
+	err = srv.Run()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "listen on :"+port)
+}
+
+func TestHttpServer_RunLifecycle(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0", ShutdownTimeout: time.Second})
+
+	var stages []string
+	var readyOnShutdown bool
+	srv.OnStart(func(ctx context.Context) error {
+		stages = append(stages, "start")
+		return nil
+	})
+	srv.OnReady(func(ctx context.Context) error {
+		stages = append(stages, "ready")
+		assert.True(t, srv.IsReady())
+		return syscall.Kill(syscall.Getpid(), syscall.SIGTERM)
+	})
+	srv.OnShutdown(func(ctx context.Context) error {
+		stages = append(stages, "shutdown")
+		readyOnShutdown = srv.IsReady()
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Second), deadline, 500*time.Millisecond)
+		return nil
+	})
+	srv.OnStopped(func(ctx context.Context) error {
+		stages = append(stages, "stopped")
+		return nil
+	})
+
+	assert.NoError(t, srv.Run())
+	assert.Equal(t, []string{"start", "ready", "shutdown", "stopped"}, stages)
+	assert.False(t, readyOnShutdown)
+	assert.False(t, srv.IsReady())
+}
+
+func TestHttpServer_StartStop(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0", ShutdownTimeout: time.Second})
+	assert.Nil(t, srv.Addr())
+
+	require.NoError(t, srv.Start(context.Background()))
+	addr := srv.Addr()
+	require.NotNil(t, addr)
+	assert.NotEqual(t, 0, addr.(*net.TCPAddr).Port)
+	assert.True(t, srv.IsReady())
+
+	// 重复启动
+	assert.Error(t, srv.Start(context.Background()))
+
+	resp, err := http.Get("http://" + addr.String() + "/not-found")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, srv.Stop(ctx))
+	assert.Nil(t, srv.Addr())
+	assert.False(t, srv.IsReady())
+
+	_, err = http.Get("http://" + addr.String() + "/not-found")
+	assert.Error(t, err)
+
+	// 未启动时 Stop 不报错
+	assert.NoError(t, srv.Stop(context.Background()))
+}
+
+func TestHttpServer_StopConcurrent(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0", ShutdownTimeout: time.Second})
+	var shutdown, stopped atomic.Int32
+	srv.OnShutdown(func(ctx context.Context) error {
+		shutdown.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("flush failed")
+	})
+	srv.OnStopped(func(ctx context.Context) error {
+		stopped.Add(1)
+		return nil
+	})
+	require.NoError(t, srv.Start(context.Background()))
+
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = srv.Stop(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	// hook 只执行一次, 所有调用返回同一个结果
+	assert.Equal(t, int32(1), shutdown.Load())
+	assert.Equal(t, int32(1), stopped.Load())
+	for _, err := range errs {
+		require.Error(t, err)
+		assert.Same(t, errs[0], err)
+	}
+	assert.Same(t, errs[0], srv.Stop(context.Background()))
+	assert.Equal(t, int32(1), shutdown.Load())
+}
+
+func TestHttpServer_StartReadyHookError(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0"})
+	stopped := false
+	srv.OnReady(func(ctx context.Context) error {
+		return errors.New("register failed")
+	})
+	srv.OnStopped(func(ctx context.Context) error {
+		stopped = true
+		return nil
+	})
+
+	err := srv.Start(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "register failed")
+	assert.True(t, stopped)
+	assert.Nil(t, srv.Addr())
+}
+
+func TestHttpServer_RequestTimeout(t *testing.T) {
+	srv := New("test")
+	srv.SetServerConfig(Server{Port: "0", WriteTimeout: time.Second, RequestTimeout: time.Second})
+	err := srv.Start(context.Background())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "request_timeout")
+
+	srv.SetServerConfig(Server{Port: "0", WriteTimeout: 2 * time.Second, RequestTimeout: time.Second})
+	require.NoError(t, srv.Start(context.Background()))
+	// 只对当前 server 生效, 不修改全局设置
+	assert.Equal(t, time.Duration(0), middleware.DefaultTimeout())
+	require.NoError(t, srv.Stop(context.Background()))
+
+	deadline := func(h *httpServer) time.Duration {
+		web := middleware.NewWeb("/t")
+		web.Route(web.Get("/deadline").NoLogin().Handler(func(ctx coreContext.Contexts) apiErrors.Error {
+			d, ok := ctx.Deadline()
+			if ok {
+				ctx.SetData(time.Until(d).Round(time.Second).String())
+			}
+			return nil
+		}))
+		engine := h.initRoutes()
+		web.RegisterGinRoutes(engine)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/t/deadline", nil))
+		var resp struct {
+			Data string `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		d, _ := time.ParseDuration(resp.Data)
+		return d
+	}
+	s1 := NewHttpServer("s1", Server{RequestTimeout: time.Second, AccessLog: AccessLog{Disable: true}}, nil).(*httpServer)
+	s2 := NewHttpServer("s2", Server{RequestTimeout: 3 * time.Second, AccessLog: AccessLog{Disable: true}}, nil).(*httpServer)
+	assert.Equal(t, time.Second, deadline(s1))
+	assert.Equal(t, 3*time.Second, deadline(s2))
+}
+
+func TestHttpServer_ConcurrencyPerServer(t *testing.T) {
+	conf := Server{Port: "0", Concurrency: Concurrency{Enable: true}}
+	srv1 := NewHttpServer("s1", conf, nil)
+	srv2 := NewHttpServer("s2", conf, nil)
+	require.NoError(t, srv1.Start(context.Background()))
+	require.NoError(t, srv2.Start(context.Background()))
+	defer srv2.Stop(context.Background())
+
+	l1, l2 := srv1.(*httpServer).limiter, srv2.(*httpServer).limiter
+	require.NotNil(t, l1)
+	require.NotNil(t, l2)
+	assert.NotSame(t, l1, l2)
+	// 不修改全局设置
+	assert.Nil(t, middleware.ConcurrencyLimiter())
+
+	require.NoError(t, srv1.Stop(context.Background()))
+	assert.Same(t, l2, srv2.(*httpServer).limiter)
+}
+
+func TestHttpServer_MaxBodySize(t *testing.T) {
+	web := middleware.NewWeb("/b")
+	web.Route(web.Post("/echo").NoLogin().Handler(func(ctx coreContext.Contexts) apiErrors.Error {
+		return nil
+	}))
+	serve := func(h *httpServer) int {
+		engine := h.initRoutes()
+		web.RegisterGinRoutes(engine)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/b/echo", strings.NewReader("0123456789")))
+		return w.Code
+	}
+	small := NewHttpServer("s1", Server{MaxBodySize: 4, AccessLog: AccessLog{Disable: true}}, nil).(*httpServer)
+	large := NewHttpServer("s2", Server{MaxBodySize: 1024, AccessLog: AccessLog{Disable: true}}, nil).(*httpServer)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve(small))
+	assert.Equal(t, http.StatusOK, serve(large))
+	assert.Equal(t, int64(0), middleware.MaxBodySize())
+}
+
+func TestValidateRouteTimeouts(t *testing.T) {
+	handler := func(ctx coreContext.Contexts) apiErrors.Error { return nil }
+	web := middleware.NewWeb("/api")
+	web.Route(web.Get("/fast").Timeout(time.Second).Handler(handler))
+	assert.NoError(t, validateRouteTimeouts([]router.Router{web}, 2*time.Second))
+	assert.NoError(t, validateRouteTimeouts([]router.Router{web}, 0))
+
+	web.Route(web.Get("/report").Timeout(time.Minute).Handler(handler))
+	err := validateRouteTimeouts([]router.Router{web}, 2*time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GET /api/report timeout(1m0s)")
+}
@@ -0,0 +1,26 @@
+package server
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/pkg/metrics"
+)
+
+const defaultMetricsPath = "/metrics"
+
+type Metrics struct {
+	Enable bool   `mapstructure:"enable"`
+	Path   string `mapstructure:"path"` // 默认 /metrics
+}
+
+// registerMetricsRoute 输出 metrics.Default() 中的所有指标, 包括 middleware 记录的请求指标和业务自定义指标。
+// 与健康检查一致, 业务已经注册了相同的 GET 路径时不再注册
+func (h *httpServer) registerMetricsRoute(engine *gin.Engine) {
+	path := pathOrDefault(h.s.Metrics.Path, defaultMetricsPath)
+	if hasGETRoute(engine, path) {
+		log.Printf("Skip metrics route %s: already registered", path)
+		return
+	}
+	engine.GET(path, gin.WrapH(metrics.Handler()))
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpServer_MetricsRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 路由输出全局的 metrics.Default(), 每次使用新注册的指标
+	metrics.Default().Unregister("server_test_total")
+	defer metrics.Default().Unregister("server_test_total")
+	metrics.NewCounterVec("server_test_total", "Server test counter.").WithLabelValues().Inc()
+
+	srv := NewHttpServer("test", Server{Metrics: Metrics{Enable: true, Path: "/internal/metrics"}}, nil).(*httpServer)
+	engine := srv.initRoutes()
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/internal/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "server_test_total 1\n")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHttpServer_MetricsRouteRegistered(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := NewHttpServer("test", Server{Metrics: Metrics{Enable: true}}, nil).(*httpServer)
+	engine := gin.New()
+	// 业务已经定义的路径保留业务的 handler
+	engine.GET("/metrics", func(c *gin.Context) { c.String(http.StatusOK, "custom") })
+	assert.NotPanics(t, func() { srv.registerMetricsRoute(engine) })
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "custom", w.Body.String())
+}
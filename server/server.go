@@ -2,14 +2,21 @@ package server
 
 import (
 	"context"
+	osErr "errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/rentiansheng/go-api-component/middleware"
+	"github.com/rentiansheng/go-api-component/pkg/concurrency"
 	"github.com/rentiansheng/go-api-component/pkg/logger"
 	"github.com/rentiansheng/go-api-component/server/router"
 )
@@ -19,27 +26,60 @@ type Server struct {
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	RequestTimeout  time.Duration `mapstructure:"request_timeout"`
+	MaxBodySize     int64         `mapstructure:"max_body_size"`
 	Cors            Cors          `mapstructure:"cors"`
+	TLS             TLS           `mapstructure:"tls"`
+	Health          Health        `mapstructure:"health"`
+	Metrics         Metrics       `mapstructure:"metrics"`
+	Admin           Admin         `mapstructure:"admin"`
+	AccessLog       AccessLog     `mapstructure:"access_log"`
+	Concurrency     Concurrency   `mapstructure:"concurrency"`
 }
 
-type Cors struct {
-	AllowedHeaders []string `mapstructure:"allowed_headers"`
-	AllowedMethods []string `mapstructure:"allowed_methods"`
-	AllowedDomains []string `mapstructure:"allowed_domains"`
-	CookiesAllowed bool     `mapstructure:"cookies_allowed"`
-	EnableCORS     bool     `mapstructure:"enable_cors"`
+type httpServer struct {
+	name   string
+	s      Server
+	l      *logger.Log
+	hooks  hooks
+	ready  atomic.Bool
+	health HealthChecker
+
+	mu        sync.Mutex
+	limiter   *concurrency.Limiter // Concurrency.Enable 时的并发限制
+	accessLog *accessLogger        // 关闭时关闭访问日志的文件
+	srv       *http.Server
+	ln        net.Listener
+	done      chan struct{} // serve 结束后关闭
+	serveErr  error
+	admin     *adminServer
+	stopping  *stopState // 当前这次启动的关闭状态, Start 时重置
 }
 
-type httpServer struct {
-	name string
-	s    Server
-	l    *logger.Log
+// stopState 保证同一次启动只关闭一次, 重复或者并发调用 Stop 返回第一次的结果
+type stopState struct {
+	once sync.Once
+	err  error
 }
 
 type HTTPServer interface {
 	SetLogConfig(l *logger.Log)
 	SetServerConfig(s Server)
 	SetName(name string)
+	OnStart(fns ...Hook)
+	OnReady(fns ...Hook)
+	OnShutdown(fns ...Hook)
+	OnStopped(fns ...Hook)
+	IsReady() bool
+	// Health 注册健康检查, Server.Health.Enable 为 true 时提供 /healthz, /livez, /readyz
+	Health() HealthChecker
+	// Start 监听端口并在后台处理请求, 不阻塞
+	Start(ctx context.Context) error
+	// Stop 优雅关闭, ctx 没有截止时间时使用 ShutdownTimeout。重复调用返回第一次的结果
+	Stop(ctx context.Context) error
+	// Addr 实际监听的地址, Port 为 "0" 时可以获取系统分配的端口。未启动时返回 nil
+	Addr() net.Addr
+	// Run 启动服务并阻塞, 直到收到 SIGINT/SIGTERM 或者服务异常退出
 	Run() error
 }
 
@@ -61,7 +101,8 @@ func New(name string) HTTPServer {
 			Port:            "8080",
 			ReadTimeout:     10 * time.Second,
 			WriteTimeout:    10 * time.Second,
-			ShutdownTimeout: 5 * time.Second,
+			ShutdownTimeout: defaultShutdownTimeout,
+			Health:          Health{Enable: true},
 		},
 		l: nil,
 	}
@@ -79,14 +120,65 @@ func (h *httpServer) SetName(name string) {
 	h.name = name
 }
 
-// Run initializes and starts the HTTP server
+// Run initializes and starts the HTTP server, blocks until SIGINT/SIGTERM or the listener fails
 func (h *httpServer) Run() error {
+	// 捕获退出信号
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	if err := h.Start(context.Background()); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	done := h.done
+	h.mu.Unlock()
+
+	select {
+	case <-done:
+		return h.Stop(context.Background())
+	case sig := <-stop:
+		log.Printf("received signal %s, shutting down server...", sig)
+	}
+
+	return h.Stop(context.Background())
+}
+
+func (h *httpServer) Start(ctx context.Context) error {
+	if h.Addr() != nil {
+		return fmt.Errorf("server %s already started", h.name)
+	}
 
 	// Configure logging
 	if h.l != nil {
 		logger.Config(h.name, *h.l)
 	}
-	
+
+	if err := runHooks(ctx, "start", h.hooks.onStart); err != nil {
+		return err
+	}
+
+	if _, err := newCorsHandler(h.s.Cors); err != nil {
+		return fmt.Errorf("server %s cors config: %w", h.name, err)
+	}
+	if err := h.s.AccessLog.validate(); err != nil {
+		return fmt.Errorf("server %s access log config: %w", h.name, err)
+	}
+	if h.s.RequestTimeout > 0 && h.s.WriteTimeout > 0 && h.s.RequestTimeout >= h.s.WriteTimeout {
+		return fmt.Errorf("server %s request_timeout(%s) must be less than write_timeout(%s)", h.name, h.s.RequestTimeout, h.s.WriteTimeout)
+	}
+	if err := validateRouteTimeouts(router.Get(), h.s.WriteTimeout); err != nil {
+		return fmt.Errorf("server %s %w", h.name, err)
+	}
+	limiter, err := h.newConcurrencyLimiter()
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	h.limiter = limiter
+	h.mu.Unlock()
+
 	router := h.initRoutes()
 
 	// Create server with timeouts
@@ -97,26 +189,144 @@ func (h *httpServer) Run() error {
 		WriteTimeout: h.s.WriteTimeout,
 	}
 
-	stop := make(chan os.Signal, 1)
+	if h.s.TLS.Enable {
+		tlsConfig, err := buildTLSConfig(h.s.TLS)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+	}
 
-	go func() {
-		if err := server.ListenAndServe(); err != nil {
-			log.Println("failed to start server:", err)
+	// 先监听端口, 端口被占用等错误直接返回
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return fmt.Errorf("server %s listen on %s: %w", h.name, server.Addr, err)
+	}
+
+	var admin *adminServer
+	if h.s.Admin.Enable {
+		if admin, err = h.startAdmin(router); err != nil {
+			_ = ln.Close()
+			return err
 		}
-		stop <- syscall.SIGABRT
-		return
+	}
+
+	h.mu.Lock()
+	if h.srv != nil {
+		h.mu.Unlock()
+		_ = ln.Close()
+		if admin != nil {
+			_ = admin.stop(ctx)
+		}
+		return fmt.Errorf("server %s already started", h.name)
+	}
+	done := make(chan struct{})
+	h.srv, h.ln, h.done, h.serveErr, h.admin = server, ln, done, nil, admin
+	h.stopping = &stopState{}
+	h.mu.Unlock()
+
+	go func() {
+		err := h.serve(server, ln)
+		h.mu.Lock()
+		h.serveErr = err
+		h.mu.Unlock()
+		close(done)
 	}()
 
-	// 捕获退出信号
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	h.ready.Store(true)
+	log.Printf("server %s listening on %s", h.name, ln.Addr())
+	if err := runHooks(ctx, "ready", h.hooks.onReady); err != nil {
+		return osErr.Join(err, h.Stop(context.WithoutCancel(ctx)))
+	}
+	return nil
+}
+
+// Stop 先将 readiness 置为 false, 然后排空请求
+func (h *httpServer) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	server, done, stopping := h.srv, h.done, h.stopping
+	h.mu.Unlock()
+	if stopping == nil {
+		return nil
+	}
+	// 并发调用时等待第一次调用完成
+	stopping.once.Do(func() {
+		stopping.err = h.shutdown(ctx, server, done)
+	})
+	return stopping.err
+}
+
+func (h *httpServer) shutdown(ctx context.Context, server *http.Server, done chan struct{}) error {
+	h.ready.Store(false)
 
-	<-stop // 阻塞直到退出
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.shutdownTimeout())
+		defer cancel()
+	}
 
-	log.Println("Shutting down server...")
+	// 等待负载均衡感知 readiness 变化, 摘除流量
+	if delay := h.s.Health.ShutdownDelay; delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	return server.Shutdown(ctx)
+	var errs []error
+	if err := runHooks(ctx, "shutdown", h.hooks.onShutdown); err != nil {
+		errs = append(errs, err)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("server %s shutdown: %w", h.name, err))
+	}
+	<-done
+
+	h.mu.Lock()
+	if err := h.serveErr; err != nil && !osErr.Is(err, http.ErrServerClosed) {
+		errs = append(errs, fmt.Errorf("server %s stopped serving: %w", h.name, err))
+	}
+	admin, accessLog := h.admin, h.accessLog
+	h.srv, h.ln, h.done, h.serveErr, h.admin, h.limiter, h.accessLog = nil, nil, nil, nil, nil, nil, nil
+	h.mu.Unlock()
+
+	// 请求已经排空, 不会再写访问日志
+	if accessLog != nil {
+		if err := accessLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("server %s close access log: %w", h.name, err))
+		}
+	}
+
+	// 运维端口在业务请求排空之后再关闭
+	if admin != nil {
+		if err := admin.stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("server %s admin shutdown: %w", h.name, err))
+		}
+	}
+
+	if err := runHooks(context.WithoutCancel(ctx), "stopped", h.hooks.onStopped); err != nil {
+		errs = append(errs, err)
+	}
+	log.Printf("server %s stopped", h.name)
+
+	return osErr.Join(errs...)
+}
+
+func (h *httpServer) Addr() net.Addr {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ln == nil {
+		return nil
+	}
+	return h.ln.Addr()
+}
+
+func (h *httpServer) serve(server *http.Server, ln net.Listener) error {
+	if server.TLSConfig != nil {
+		// 证书由 TLSConfig.GetCertificate 提供
+		return server.ServeTLS(ln, "", "")
+	}
+	return server.Serve(ln)
 }
 
 func (h *httpServer) initRoutes() *gin.Engine {
@@ -127,10 +337,33 @@ func (h *httpServer) initRoutes() *gin.Engine {
 	engine := gin.New()
 
 	// Add middleware
-	engine.Use(gin.Logger())
+	if !h.s.AccessLog.Disable {
+		accessLog, err := newAccessLogger(h.s.AccessLog)
+		if err != nil {
+			// Start 中已经校验过配置
+			panic(err)
+		}
+		engine.Use(accessLog.middleware())
+		h.mu.Lock()
+		h.accessLog = accessLog
+		h.mu.Unlock()
+	}
 	engine.Use(gin.Recovery())
+	// 并发限制等设置只对当前 server 的 engine 生效
+	h.mu.Lock()
+	limiter := h.limiter
+	h.mu.Unlock()
+	if limiter != nil {
+		engine.Use(middleware.ConcurrencyLimiterHandler(limiter))
+	}
+	if h.s.RequestTimeout > 0 {
+		engine.Use(middleware.DefaultTimeoutHandler(h.s.RequestTimeout))
+	}
+	if h.s.MaxBodySize > 0 {
+		engine.Use(middleware.MaxBodySizeHandler(h.s.MaxBodySize))
+	}
 
-	if h.s.Cors.EnableCORS {
+	if h.s.Cors.enabled() {
 		// Add CORS middleware
 		engine.Use(h.corsMiddleware())
 	}
@@ -138,64 +371,15 @@ func (h *httpServer) initRoutes() *gin.Engine {
 	// Register routes
 	registerRoutes(engine, router.Get())
 
-	return engine
-}
-
-func (h *httpServer) corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		s := h.s.Cors
-		
-		origin := c.Request.Header.Get("Origin")
-		if origin != "" {
-			// Check if origin is allowed
-			allowed := false
-			for _, domain := range s.AllowedDomains {
-				if domain == "*" || domain == origin {
-					allowed = true
-					break
-				}
-			}
-			
-			if allowed {
-				c.Header("Access-Control-Allow-Origin", origin)
-			}
-		}
-		
-		// Set other CORS headers
-		if len(s.AllowedMethods) > 0 {
-			methods := ""
-			for i, method := range s.AllowedMethods {
-				if i > 0 {
-					methods += ", "
-				}
-				methods += method
-			}
-			c.Header("Access-Control-Allow-Methods", methods)
-		}
-		
-		if len(s.AllowedHeaders) > 0 {
-			headers := ""
-			for i, header := range s.AllowedHeaders {
-				if i > 0 {
-					headers += ", "
-				}
-				headers += header
-			}
-			c.Header("Access-Control-Allow-Headers", headers)
-		}
-		
-		if s.CookiesAllowed {
-			c.Header("Access-Control-Allow-Credentials", "true")
-		}
-		
-		// Handle preflight requests
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		
-		c.Next()
+	// 在业务路由之后注册, 业务已经定义的路径不再注册
+	if h.s.Health.Enable {
+		h.registerHealthRoutes(engine)
+	}
+	if h.s.Metrics.Enable {
+		h.registerMetricsRoute(engine)
 	}
+
+	return engine
 }
 
 func registerRoutes(engine *gin.Engine, routers []router.Router) {
@@ -204,3 +388,28 @@ func registerRoutes(engine *gin.Engine, routers []router.Router) {
 		log.Printf("Registered router: %T", r)
 	}
 }
+
+// validateRouteTimeouts 路由的超时时间需要小于 write_timeout, 否则超时响应写出之前连接已经被关闭
+func validateRouteTimeouts(routers []router.Router, writeTimeout time.Duration) error {
+	if writeTimeout <= 0 {
+		return nil
+	}
+	for _, r := range routers {
+		l, ok := r.(interface {
+			RouteInfos() []middleware.RouteInfo
+		})
+		if !ok {
+			continue
+		}
+		for _, info := range l.RouteInfos() {
+			if info.Timeout == "" {
+				continue
+			}
+			timeout, err := time.ParseDuration(info.Timeout)
+			if err == nil && timeout >= writeTimeout {
+				return fmt.Errorf("route %s %s timeout(%s) must be less than write_timeout(%s)", info.Method, info.Path, timeout, writeTimeout)
+			}
+		}
+	}
+	return nil
+}
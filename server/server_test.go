@@ -156,8 +156,8 @@ func TestHttpServer_CORSMiddleware(t *testing.T) {
 			origin:        "http://example.com",
 			method:        "GET",
 			expectOrigin:  "http://example.com",
-			expectMethods: "GET, POST, PUT",
-			expectHeaders: "Content-Type, Authorization",
+			expectMethods: "",
+			expectHeaders: "",
 			expectCreds:   "true",
 		},
 		{
@@ -165,8 +165,8 @@ func TestHttpServer_CORSMiddleware(t *testing.T) {
 			origin:        "http://localhost:3000",
 			method:        "POST",
 			expectOrigin:  "http://localhost:3000",
-			expectMethods: "GET, POST, PUT",
-			expectHeaders: "Content-Type, Authorization",
+			expectMethods: "",
+			expectHeaders: "",
 			expectCreds:   "true",
 		},
 		{
@@ -174,9 +174,9 @@ func TestHttpServer_CORSMiddleware(t *testing.T) {
 			origin:        "http://evil.com",
 			method:        "GET",
 			expectOrigin:  "",
-			expectMethods: "GET, POST, PUT",
-			expectHeaders: "Content-Type, Authorization",
-			expectCreds:   "true",
+			expectMethods: "",
+			expectHeaders: "",
+			expectCreds:   "",
 		},
 	}
 
@@ -192,9 +192,8 @@ func TestHttpServer_CORSMiddleware(t *testing.T) {
 			middleware := srv.corsMiddleware()
 			middleware(c)
 
-			if tt.expectOrigin != "" {
-				assert.Equal(t, tt.expectOrigin, w.Header().Get("Access-Control-Allow-Origin"))
-			}
+			assert.Equal(t, tt.expectOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "Origin", w.Header().Get("Vary"))
 			assert.Equal(t, tt.expectMethods, w.Header().Get("Access-Control-Allow-Methods"))
 			assert.Equal(t, tt.expectHeaders, w.Header().Get("Access-Control-Allow-Headers"))
 			assert.Equal(t, tt.expectCreds, w.Header().Get("Access-Control-Allow-Credentials"))
@@ -230,7 +229,8 @@ func TestHttpServer_CORSMiddleware_Wildcard(t *testing.T) {
 	middleware := srv.corsMiddleware()
 	middleware(c)
 
-	assert.Equal(t, "http://any-domain.com", w.Header().Get("Access-Control-Allow-Origin"))
+	// 配置 * 时返回 *, 不回显 origin
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
 }
 
 func TestHttpServer_CORSMiddleware_PreflightRequest(t *testing.T) {
@@ -256,13 +256,16 @@ func TestHttpServer_CORSMiddleware_PreflightRequest(t *testing.T) {
 
 	req := httptest.NewRequest("OPTIONS", "/test", nil)
 	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
 	c.Request = req
 
 	middleware := srv.corsMiddleware()
 	middleware(c)
 
-	// OPTIONS request should return 204
+	// Preflight request should return 204
 	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, POST, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type, Authorization", w.Header().Get("Access-Control-Allow-Headers"))
 }
 
 func TestRegisterRoutes(t *testing.T) {
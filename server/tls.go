@@ -0,0 +1,182 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultCertReloadInterval = 10 * time.Second
+
+type TLS struct {
+	Enable       bool     `mapstructure:"enable"`
+	CertFile     string   `mapstructure:"cert_file"`
+	KeyFile      string   `mapstructure:"key_file"`
+	MinVersion   string   `mapstructure:"min_version"` // 1.0, 1.1, 1.2, 1.3. 默认 1.2
+	CipherSuites []string `mapstructure:"cipher_suites"`
+	// ClientCAFile 配置后会校验客户端证书(mTLS)
+	ClientCAFile string `mapstructure:"client_ca_file"`
+	// ClientAuthOptional 为 true 时客户端可以不提供证书，提供了则必须校验通过
+	ClientAuthOptional bool `mapstructure:"client_auth_optional"`
+	// ReloadInterval 检查证书文件是否变化的最小间隔，默认 10s
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// buildTLSConfig 根据配置生成 tls.Config, 证书通过 GetCertificate 获取，文件变化后自动重新加载
+func buildTLSConfig(t TLS) (*tls.Config, error) {
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, fmt.Errorf("tls cert_file and key_file are required")
+	}
+
+	minVersion := uint16(tls.VersionTLS12)
+	if t.MinVersion != "" {
+		v, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(t.MinVersion), "tls")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls min_version: %s", t.MinVersion)
+		}
+		minVersion = v
+	}
+
+	cipherSuites, err := parseCipherSuites(t.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newCertReloader(t.CertFile, t.KeyFile, t.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in tls client_ca_file: %s", t.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if t.ClientAuthOptional {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return cfg, nil
+}
+
+func parseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls cipher suite: %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// certReloader 缓存证书, 每隔 interval 检查一次证书文件的修改时间，变化后重新加载。
+// 重新加载失败时继续使用旧证书
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	if interval <= 0 {
+		interval = defaultCertReloadInterval
+	}
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) load() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load tls key pair: %w", err)
+	}
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *certReloader) modTimes() (certMod, keyMod time.Time, err error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return certMod, keyMod, fmt.Errorf("stat tls cert_file: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return certMod, keyMod, fmt.Errorf("stat tls key_file: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+		certMod, keyMod, err := r.modTimes()
+		if err == nil && (!certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod)) {
+			if err := r.load(); err != nil {
+				log.Printf("reload tls certificate failed, keep using the previous one. err: %v", err)
+			}
+		}
+	}
+	return r.cert, nil
+}
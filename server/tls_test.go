@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert 生成自签名证书写入 dir, 返回证书和私钥路径
+func writeTestCert(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "server.crt")
+	keyFile := filepath.Join(dir, "server.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "v1")
+
+	cfg, err := buildTLSConfig(TLS{
+		Enable:       true,
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+		ClientCAFile: certFile,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+
+	cert, err := cfg.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.NotNil(t, cert)
+}
+
+func TestBuildTLSConfig_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "v1")
+
+	tests := []struct {
+		name string
+		conf TLS
+	}{
+		{name: "missing cert", conf: TLS{KeyFile: keyFile}},
+		{name: "bad version", conf: TLS{CertFile: certFile, KeyFile: keyFile, MinVersion: "0.9"}},
+		{name: "bad cipher", conf: TLS{CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"NOPE"}}},
+		{name: "bad client ca", conf: TLS{CertFile: certFile, KeyFile: keyFile, ClientCAFile: filepath.Join(dir, "none.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := buildTLSConfig(tt.conf)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCertReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "v1")
+
+	r, err := newCertReloader(certFile, keyFile, time.Millisecond)
+	require.NoError(t, err)
+
+	first, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(first.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "v1", leaf.Subject.CommonName)
+
+	// 证书轮换
+	writeTestCert(t, dir, "v2")
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+	require.NoError(t, os.Chtimes(keyFile, future, future))
+	time.Sleep(2 * time.Millisecond)
+
+	second, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err = x509.ParseCertificate(second.Certificate[0])
+	require.NoError(t, err)
+	assert.Equal(t, "v2", leaf.Subject.CommonName)
+}
+
+func TestHttpServer_StartTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCert(t, dir, "localhost")
+
+	srv := New("test")
+	srv.SetServerConfig(Server{
+		Port: "0",
+		TLS:  TLS{Enable: true, CertFile: certFile, KeyFile: keyFile},
+	})
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Stop(context.Background())
+
+	pemBytes, err := os.ReadFile(certFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(pemBytes))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	_, port, _ := net.SplitHostPort(srv.Addr().String())
+	resp, err := client.Get("https://localhost:" + port + "/not-found")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.NotNil(t, resp.TLS)
+}